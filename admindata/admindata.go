@@ -0,0 +1,128 @@
+// Package admindata reads the WOF admin data once for everything that needs
+// it, as it's tens of thousands of large files.
+package admindata
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/saracen/walker"
+	"github.com/sfomuseum/go-edtf"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+
+	uri "github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+// FeatureFunc is passed each feature in the admin data.
+type FeatureFunc func(ctx context.Context, f []byte) error
+
+// Filter picks the features Load passes on, so the ones nothing needs aren't
+// read at all where that can be avoided.
+type Filter struct {
+	// Placetypes are the placetypes to load, or nil for all of them
+	Placetypes []string
+	// SkipDeprecated leaves out deprecated features
+	SkipDeprecated bool
+}
+
+// Merge returns a Filter passing every feature any of the filters would. A
+// nil Filter passes everything.
+func Merge(filters ...*Filter) *Filter {
+	merged := &Filter{Placetypes: []string{}, SkipDeprecated: true}
+
+	for _, filter := range filters {
+		if filter == nil {
+			return nil
+		}
+
+		if filter.Placetypes == nil {
+			merged.Placetypes = nil
+		} else if merged.Placetypes != nil {
+			for _, pt := range filter.Placetypes {
+				if !slices.Contains(merged.Placetypes, pt) {
+					merged.Placetypes = append(merged.Placetypes, pt)
+				}
+			}
+		}
+
+		merged.SkipDeprecated = merged.SkipDeprecated && filter.SkipDeprecated
+	}
+
+	return merged
+}
+
+// match returns whether the filter passes the feature.
+func (filter *Filter) match(f []byte) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.Placetypes != nil && !slices.Contains(filter.Placetypes, gjson.GetBytes(f, "properties.wof:placetype").String()) {
+		return false
+	}
+
+	return !filter.SkipDeprecated || edtf.IsUnspecified(properties.Deprecated(f))
+}
+
+// Load reads the admin data at path, either a directory or a WOF SQLite
+// distribution, passing each feature the filter matches once to each of fns
+// in turn. Features are loaded from several goroutines at once, so fns must be
+// safe to call concurrently. Alternate geometries are skipped.
+func Load(ctx context.Context, path string, filter *Filter, fns ...FeatureFunc) error {
+	if IsSQLite(path) {
+		return loadSQLite(ctx, path, filter, fns)
+	}
+
+	var loaded, skippedAlt, skippedFilter atomic.Uint64
+
+	walkFn := func(path string, fi os.FileInfo) error {
+		if fi.IsDir() || !strings.HasSuffix(path, ".geojson") {
+			return nil
+		}
+
+		isAlt, err := uri.IsAltFile(path)
+		if err == nil && isAlt {
+			skippedAlt.Add(1)
+			return nil
+		}
+
+		f, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if !filter.match(f) {
+			skippedFilter.Add(1)
+			return nil
+		}
+
+		for _, fn := range fns {
+			err = fn(ctx, f)
+			if err != nil {
+				return err
+			}
+		}
+
+		loaded.Add(1)
+		return nil
+	}
+
+	errorFn := walker.WithErrorCallback(func(path string, err error) error {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	})
+
+	err := walker.WalkWithContext(ctx, path, walkFn, errorFn)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Loaded %d admin features from %s, skipping %d alt geometries and %d features not needed", loaded.Load(), path, skippedAlt.Load(), skippedFilter.Load())
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package admindata
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tidwall/gjson"
+
+	wof_reader "github.com/whosonfirst/go-whosonfirst-reader"
+)
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"1001.geojson":             `{"properties":{"wof:id":1001}}`,
+		"1001-alt-os-hull.geojson": `{"properties":{"wof:id":1001}}`,
+		"1002.geojson":             `{"properties":{"wof:id":1002}}`,
+		"README.md":                "not a feature",
+	}
+
+	for name, body := range files {
+		err := os.WriteFile(filepath.Join(root, name), []byte(body), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var first, second atomic.Uint64
+
+	err := Load(context.Background(), root, nil,
+		func(ctx context.Context, f []byte) error {
+			first.Add(1)
+			return nil
+		},
+		func(ctx context.Context, f []byte) error {
+			second.Add(1)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Load() != 2 || second.Load() != 2 {
+		t.Errorf("Load() passed %d and %d features, want 2 to each", first.Load(), second.Load())
+	}
+}
+
+func TestLoadSQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "admin.db")
+
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+
+	_, err = rw.Exec(`CREATE TABLE geojson (id INTEGER NOT NULL, body TEXT, source TEXT, is_alt BOOLEAN, alt_label TEXT, lastmodified INTEGER);
+		INSERT INTO geojson VALUES (1001, '{"properties":{"wof:id":1001}}', '', 0, '', 0);
+		INSERT INTO geojson VALUES (1001, '{"properties":{"wof:id":1001,"src:alt_label":"os-hull"}}', '', 1, 'os-hull', 0);
+		INSERT INTO geojson VALUES (1002, '{"properties":{"wof:id":1002}}', '', 0, '', 0);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsSQLite(path) {
+		t.Fatalf("IsSQLite(%s) = false, want true", path)
+	}
+
+	var ids []int64
+	mu := sync.Mutex{}
+
+	err = Load(ctx, path, nil, func(ctx context.Context, f []byte) error {
+		mu.Lock()
+		ids = append(ids, gjson.GetBytes(f, "properties.wof:id").Int())
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(ids)
+	if !slices.Equal(ids, []int64{1001, 1002}) {
+		t.Errorf("Load() passed features %v, want 1001 and 1002", ids)
+	}
+
+	r, err := NewReader(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := wof_reader.LoadBytes(ctx, r, 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gjson.GetBytes(body, "properties.src:alt_label").Exists() {
+		t.Errorf("NewReader() read the alternate geometry of 1001, want the default one")
+	}
+
+	_, err = wof_reader.LoadBytes(ctx, r, 1003)
+	if err == nil {
+		t.Errorf("NewReader() read missing feature 1003, want an error")
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = wof_reader.LoadBytes(ctx, r, 1001)
+	if err == nil {
+		t.Errorf("NewReader() read 1001 once closed, want an error")
+	}
+}
+
+func TestLoadFilter(t *testing.T) {
+	ctx := context.Background()
+
+	features := []string{
+		`{"properties":{"wof:id":1001,"wof:placetype":"county"}}`,
+		`{"properties":{"wof:id":1002,"wof:placetype":"county","edtf:deprecated":"2020-01-01"}}`,
+		`{"properties":{"wof:id":1003,"wof:placetype":"locality"}}`,
+		`{"properties":{"wof:id":1004,"wof:placetype":"postalregion"}}`,
+	}
+
+	// The characters in the directory would end the path of a plain URI
+	root := filepath.Join(t.TempDir(), "admin?#")
+
+	err := os.Mkdir(root, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := filepath.Join(t.TempDir(), "admin.db")
+
+	rw, err := sql.Open("sqlite", created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = rw.Exec(`CREATE TABLE geojson (id INTEGER NOT NULL, body TEXT, source TEXT, is_alt BOOLEAN, alt_label TEXT, lastmodified INTEGER);
+		CREATE TABLE spr (id TEXT NOT NULL, placetype TEXT, is_deprecated INTEGER, is_alt BOOLEAN)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, body := range features {
+		err := os.WriteFile(filepath.Join(root, fmt.Sprintf("%d.geojson", 1001+i)), []byte(body), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deprecated := 0
+		if gjson.Get(body, "properties.edtf:deprecated").Exists() {
+			deprecated = 1
+		}
+
+		_, err = rw.Exec(`INSERT INTO geojson VALUES (?, ?, '', 0, '', 0)`, 1001+i, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = rw.Exec(`INSERT INTO spr VALUES (?, ?, ?, 0)`, fmt.Sprint(1001+i), gjson.Get(body, "properties.wof:placetype").String(), deprecated)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = rw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(root, "admin.db")
+
+	err = os.Rename(created, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filter *Filter
+		want   []int64
+	}{
+		{nil, []int64{1001, 1002, 1003, 1004}},
+		{&Filter{Placetypes: []string{"county"}}, []int64{1001, 1002}},
+		{&Filter{Placetypes: []string{"county", "locality"}, SkipDeprecated: true}, []int64{1001, 1003}},
+		{Merge(&Filter{Placetypes: []string{"county"}, SkipDeprecated: true}, &Filter{Placetypes: []string{"postalregion"}}), []int64{1001, 1002, 1004}},
+	}
+
+	for _, test := range tests {
+		for _, source := range []string{root, path} {
+			var ids []int64
+			mu := sync.Mutex{}
+
+			err := Load(ctx, source, test.filter, func(ctx context.Context, f []byte) error {
+				mu.Lock()
+				ids = append(ids, gjson.GetBytes(f, "properties.wof:id").Int())
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			slices.Sort(ids)
+			if !slices.Equal(ids, test.want) {
+				t.Errorf("Load(%s, %+v) passed features %v, want %v", filepath.Base(source), test.filter, ids, test.want)
+			}
+		}
+	}
+}
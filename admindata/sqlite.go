@@ -0,0 +1,254 @@
+package admindata
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	reader "github.com/whosonfirst/go-reader"
+	uri "github.com/whosonfirst/go-whosonfirst-uri"
+
+	_ "modernc.org/sqlite"
+)
+
+// The first bytes of every SQLite database file
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// IsSQLite returns whether path is a SQLite database, such as a WOF SQLite
+// distribution, rather than a directory of GeoJSON files.
+func IsSQLite(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	_, err = io.ReadFull(f, header)
+
+	return err == nil && bytes.Equal(header, sqliteHeader)
+}
+
+func openSQLite(path string) (*sql.DB, error) {
+	dsn, err := sqliteDSN(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.Open("sqlite", dsn)
+}
+
+// sqliteDSN returns a read-only URI for the database at path, escaping any
+// characters such as ? or # which would otherwise end the path.
+func sqliteDSN(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(absPath), RawQuery: "mode=ro"}
+	return u.String(), nil
+}
+
+// sqliteQuery returns the query for the bodies of the features the filter
+// matches, using the spr table to pick them so the rest aren't read.
+func sqliteQuery(filter *Filter) (string, []any) {
+	query := "SELECT body FROM geojson WHERE is_alt = 0"
+	if filter == nil {
+		return query, nil
+	}
+
+	var conditions []string
+	var args []any
+
+	if filter.Placetypes != nil {
+		conditions = append(conditions, "placetype IN ("+strings.TrimSuffix(strings.Repeat("?,", len(filter.Placetypes)), ",")+")")
+		for _, pt := range filter.Placetypes {
+			args = append(args, pt)
+		}
+	}
+
+	if filter.SkipDeprecated {
+		conditions = append(conditions, "is_deprecated != 1")
+	}
+
+	if len(conditions) == 0 {
+		return query, nil
+	}
+
+	return query + " AND id IN (SELECT id FROM spr WHERE " + strings.Join(conditions, " AND ") + ")", args
+}
+
+// loadSQLite passes each feature in the geojson table of the WOF SQLite
+// distribution at path which the filter matches to fns, leaving out
+// alternate geometries.
+func loadSQLite(ctx context.Context, path string, filter *Filter, fns []FeatureFunc) error {
+	db, err := openSQLite(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var skippedAlt, total int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FILTER (WHERE is_alt != 0), COUNT(*) FILTER (WHERE is_alt = 0) FROM geojson").Scan(&skippedAlt, &total)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	query, args := sqliteQuery(filter)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer rows.Close()
+
+	workerCount := runtime.NumCPU()
+	workChan := make(chan []byte, workerCount*2)
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < workerCount; i++ {
+		g.Go(func() error {
+			for f := range workChan {
+				for _, fn := range fns {
+					err := fn(gctx, f)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+	}
+
+	var loaded int
+
+	g.Go(func() error {
+		defer close(workChan)
+
+		for rows.Next() {
+			var body []byte
+
+			err := rows.Scan(&body)
+			if err != nil {
+				return fmt.Errorf("failed to read row of %s: %w", path, err)
+			}
+
+			select {
+			case workChan <- body:
+				loaded++
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+
+		return rows.Err()
+	})
+
+	err = g.Wait()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Loaded %d admin features from %s, skipping %d alt geometries and %d features not needed", loaded, path, skippedAlt, total-loaded)
+
+	return nil
+}
+
+// Reader reads features by ID from the admin data. It must be closed once
+// it's no longer needed.
+type Reader interface {
+	reader.Reader
+	io.Closer
+}
+
+// fsReader reads features from a directory, with nothing to close.
+type fsReader struct {
+	reader.Reader
+}
+
+func (fsReader) Close() error {
+	return nil
+}
+
+// sqliteReader reads features by ID from the geojson table of a WOF SQLite
+// distribution.
+type sqliteReader struct {
+	db   *sql.DB
+	path string
+}
+
+// NewReader returns a reader for the features in the admin data at path,
+// which is either a directory or a WOF SQLite distribution.
+func NewReader(ctx context.Context, path string) (Reader, error) {
+	if !IsSQLite(path) {
+		r, err := reader.NewReader(ctx, fmt.Sprintf("fs://%s", path))
+		if err != nil {
+			return nil, err
+		}
+
+		return fsReader{r}, nil
+	}
+
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteReader{db: db, path: path}, nil
+}
+
+type readSeekCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekCloser) Close() error {
+	return nil
+}
+
+// Read returns the feature with the ID in the path, e.g. 101/750/581/101750581.geojson.
+func (r *sqliteReader) Read(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	id, args, err := uri.ParseURI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.IsAlternate {
+		return nil, fmt.Errorf("reading alternate geometries from %s isn't supported", r.path)
+	}
+
+	var body []byte
+	err = r.db.QueryRowContext(ctx, "SELECT body FROM geojson WHERE id = ? AND is_alt = 0", id).Scan(&body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%d not found in %s", id, r.path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return readSeekCloser{bytes.NewReader(body)}, nil
+}
+
+// Close closes the SQLite database.
+func (r *sqliteReader) Close() error {
+	return r.db.Close()
+}
+
+// ReaderURI returns the path, as features aren't stored at their own URIs.
+func (r *sqliteReader) ReaderURI(ctx context.Context, path string) string {
+	return path
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/paulmach/orb"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-whosonfirst-feature/geometry"
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/admindata"
+	"github.com/whosonfirst/wof-sync-os-postcodes/hierarchyaudit"
+	"github.com/whosonfirst/wof-sync-os-postcodes/onsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
+)
+
+// runAuditHierarchies implements the `audit-hierarchies` subcommand, which
+// reports postcodes whose hierarchies disagree with their ONS district,
+// county and region codes, without changing anything.
+func runAuditHierarchies(args []string) {
+	fs := flag.NewFlagSet("audit-hierarchies", flag.ExitOnError)
+	var onsCSVPath = fs.String("ons-csv-path", "", "The path to the ONS postcodes CSV, gzipped CSV or ONSPD release zip")
+	var wofPostalcodesPath = fs.String("wof-postalcodes-path", "", "The path to the WOF postalcodes data")
+	var wofAdminDataPath = fs.String("wof-admin-data-path", "", "The path to the GB admin data directory, or a WOF SQLite distribution of it")
+	var prefixFilter = fs.String("prefix-filter", "", "Just audit the postcodes starting with the string")
+	var outputPath = fs.String("output", "", "The path to write the CSV report to, defaults to stdout")
+	fs.Parse(args)
+
+	if *onsCSVPath == "" || *wofPostalcodesPath == "" || *wofAdminDataPath == "" {
+		log.Fatal("-ons-csv-path, -wof-postalcodes-path and -wof-admin-data-path are all required")
+	}
+
+	ctx := context.Background()
+
+	log.Print("Building ONS database")
+	db := onsdb.NewONSDB(*onsCSVPath)
+	db.SetInvalidRowPolicy(onsdb.InvalidRowsSkip)
+	err := db.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Print("Building postalregions database")
+	regionDB := postalregionsdb.NewPostalRegionsDB(*wofAdminDataPath)
+	err = regionDB.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	absAdminPath, err := filepath.Abs(*wofAdminDataPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := admindata.NewReader(ctx, absAdminPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+
+	auditor := hierarchyaudit.NewAuditor(regionDB, r)
+
+	var disagreements []*hierarchyaudit.Disagreement
+	var audited int
+	mu := sync.Mutex{}
+
+	cb := func(f []byte) error {
+		postcode := gjson.GetBytes(f, "properties.wof:name").String()
+
+		canonical, err := postcodevalidator.Normalise(postcode)
+		if err != nil || !strings.HasPrefix(canonical, *prefixFilter) {
+			return nil
+		}
+
+		// Only current postcodes with a point from the source were PIPed
+		if gjson.GetBytes(f, "properties.src:geom").String() != "os" || !isCurrent(f) {
+			return nil
+		}
+
+		pc, err := db.Lookup(canonical)
+		if err != nil || pc == nil {
+			return err
+		}
+
+		geom, err := geometry.Geometry(f)
+		if err != nil {
+			return err
+		}
+
+		point, ok := geom.Geometry().(orb.Point)
+		if !ok {
+			return nil
+		}
+
+		id, err := properties.Id(f)
+		if err != nil {
+			return err
+		}
+
+		found, err := auditor.Audit(ctx, id, point, properties.Hierarchies(f), pc)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		disagreements = append(disagreements, found...)
+		audited++
+		mu.Unlock()
+
+		return nil
+	}
+
+	log.Print("Walking over WOF postcodes")
+	err = wofdata.NewWOFData(*wofPostalcodesPath, nil).Iterate(cb)
+	if err != nil {
+		log.Fatalf("Iteration failed: %s", err)
+	}
+
+	sort.Slice(disagreements, func(i, j int) bool {
+		if disagreements[i].Postcode != disagreements[j].Postcode {
+			return disagreements[i].Postcode < disagreements[j].Postcode
+		}
+
+		return disagreements[i].Code < disagreements[j].Code
+	})
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "" {
+		f, err := os.Create(*outputPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	err = writeAuditReport(out, disagreements)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Audit: %d postcodes checked, %d disagreements with ONS codes", audited, len(disagreements))
+}
+
+// isCurrent returns whether the feature is neither ceased nor deprecated.
+func isCurrent(f []byte) bool {
+	current, err := properties.IsCurrent(f)
+	if err != nil {
+		return false
+	}
+
+	return current.Flag() != 0
+}
+
+// writeAuditReport writes one CSV row per disagreement.
+func writeAuditReport(out io.Writer, disagreements []*hierarchyaudit.Disagreement) error {
+	w := csv.NewWriter(out)
+
+	err := w.Write([]string{"postcode", "wof_id", "gss_code", "placetype", "expected_id", "hierarchy_ids", "distance_m"})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range disagreements {
+		ids := make([]string, len(d.HierarchyIDs))
+		for i, id := range d.HierarchyIDs {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+
+		w.Write([]string{d.Postcode, strconv.FormatInt(d.WofID, 10), d.Code, d.Placetype, strconv.FormatInt(d.ExpectedID, 10), strings.Join(ids, ";"), fmt.Sprintf("%.1f", d.Distance)})
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sfomuseum/go-edtf"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
+)
+
+// outwardStatus is whether an outward code has any live postcodes once
+// synced, and if not when the last of them was ceased.
+type outwardStatus struct {
+	live      bool
+	cessation time.Time
+}
+
+// outwardStatuses groups the ONS and WOF postcodes by outward code. It's safe
+// to add to from several goroutines at once.
+type outwardStatuses struct {
+	mu       sync.Mutex
+	outwards map[string]*outwardStatus
+}
+
+func newOutwardStatuses() *outwardStatuses {
+	return &outwardStatuses{outwards: make(map[string]*outwardStatus)}
+}
+
+// add records a postcode as live, or as ceased on the date given, which is
+// zero if it isn't known.
+func (s *outwardStatuses) add(postcode string, live bool, cessation time.Time) {
+	parsed, err := postcodevalidator.Parse(postcode)
+	if err != nil || !listedByONS(parsed.Category) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.outwards[parsed.Outward()]
+	if status == nil {
+		status = &outwardStatus{}
+		s.outwards[parsed.Outward()] = status
+	}
+
+	status.live = status.live || live
+
+	if cessation.After(status.cessation) {
+		status.cessation = cessation
+	}
+}
+
+// addSource records every postcode in the source.
+func (s *outwardStatuses) addSource(source postcodesource.PostcodeSource) error {
+	return source.Iterate(func(pc *postcodesource.Record) error {
+		if pc.Cessation == "" {
+			s.add(pc.Postcode, true, time.Time{})
+			return nil
+		}
+
+		cessation, err := time.Parse("200601", pc.Cessation)
+		if err != nil {
+			log.Printf("Invalid cessation date %s for %s, ignoring it", pc.Cessation, pc.Postcode)
+		}
+
+		s.add(pc.Postcode, false, cessation)
+		return nil
+	})
+}
+
+// ceasePostalRegions ceases every postalregion whose postcodes are all
+// ceased, as of the latest of their cessation dates, or the date given if
+// none are known. Ceased postalregions which have live postcodes again are
+// reported, or made current again if uncease is set.
+func ceasePostalRegions(source postcodesource.PostcodeSource, statuses *outwardStatuses, regionDB *postalregionsdb.PostalRegionsDB, wof *wofdata.WOFData, date time.Time, prefixFilter string, uncease bool, dryRun bool) error {
+	err := statuses.addSource(source)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(regionDB.Regions))
+	for name := range regionDB.Regions {
+		if strings.HasPrefix(name, prefixFilter) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var ceased int
+	var unceased int
+	var revived int
+
+	for _, name := range names {
+		region := regionDB.Regions[name]
+		status := statuses.outwards[name]
+
+		// We know nothing about outward codes without any postcodes, so
+		// leave them be
+		if status == nil {
+			continue
+		}
+
+		isCeased := !edtf.IsUnspecified(region.Cessation)
+
+		if !status.live && !isCeased {
+			cessation := status.cessation
+			if cessation.IsZero() {
+				cessation = date
+			}
+
+			changed, err := wof.CeasePostalRegion(region.WofID, cessation, dryRun)
+			if err != nil {
+				return err
+			}
+
+			if changed {
+				log.Printf("Ceased postalregion with no live postcodes: %s (ID %d)", name, region.WofID)
+				ceased++
+			}
+
+			continue
+		}
+
+		if status.live && isCeased {
+			if !uncease {
+				log.Printf("Ceased postalregion has live postcodes again: %s (ID %d)", name, region.WofID)
+				revived++
+				continue
+			}
+
+			changed, err := wof.UnceasePostalRegion(region.WofID, dryRun)
+			if err != nil {
+				return err
+			}
+
+			if changed {
+				log.Printf("Unceased postalregion with live postcodes: %s (ID %d)", name, region.WofID)
+				unceased++
+			}
+		}
+	}
+
+	log.Printf("Postalregion stats: %d ceased, %d unceased, %d ceased with live postcodes", ceased, unceased, revived)
+
+	return nil
+}
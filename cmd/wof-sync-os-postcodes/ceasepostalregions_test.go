@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sfomuseum/go-edtf"
+	"github.com/tidwall/gjson"
+	export "github.com/whosonfirst/go-whosonfirst-export/v2"
+	uri "github.com/whosonfirst/go-whosonfirst-uri"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
+)
+
+// writePostalRegions writes a postalregion for each outward code to a
+// temporary admin data directory, ceased if its cessation is specified.
+func writePostalRegions(t *testing.T, cessations map[string]string) (string, *postalregionsdb.PostalRegionsDB) {
+	root := t.TempDir()
+	regionDB := postalregionsdb.NewPostalRegionsDB(root)
+
+	names := slices.Sorted(maps.Keys(cessations))
+
+	for i, name := range names {
+		id := int64(1001 + i)
+		cessation := cessations[name]
+
+		isCurrent := 0
+		if edtf.IsUnspecified(cessation) {
+			isCurrent = 1
+		}
+
+		body := fmt.Sprintf(`{"type":"Feature","id":%d,"properties":{"wof:id":%d,"wof:name":"%s","wof:placetype":"postalregion","wof:country":"GB","wof:repo":"whosonfirst-data-admin-gb","edtf:cessation":"%s","mz:is_current":%d,"wof:lastmodified":0},"geometry":{"type":"Point","coordinates":[-2.1,57.1]}}`, id, id, name, cessation, isCurrent)
+
+		path, err := uri.Id2AbsPath(root, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(body), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		regionDB.AddRegion(&postalregionsdb.PostalRegion{Name: name, WofID: id, Cessation: cessation})
+	}
+
+	return root, regionDB
+}
+
+func readPostalRegion(t *testing.T, root string, region *postalregionsdb.PostalRegion) (cessation string, isCurrent int64) {
+	path, err := uri.Id2AbsPath(root, region.WofID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gjson.GetBytes(body, "properties.edtf:cessation").String(), gjson.GetBytes(body, "properties.mz:is_current").Int()
+}
+
+func TestCeasePostalRegions(t *testing.T) {
+	source := postcodesource.NewMemorySource(
+		&postcodesource.Record{Postcode: "AB1 0AA", Cessation: "201801"},
+		&postcodesource.Record{Postcode: "AB1 0AB", Cessation: "201903"},
+		&postcodesource.Record{Postcode: "AB2 0AA"},
+		&postcodesource.Record{Postcode: "AB3 0AA"},
+		&postcodesource.Record{Postcode: "AB3 0AB", Cessation: "201801"},
+	)
+
+	root, regionDB := writePostalRegions(t, map[string]string{
+		// Every postcode ceased
+		"AB1": "",
+		// Live again
+		"AB2": "2015-01-01",
+		// Still live
+		"AB3": "",
+		// No postcodes at all
+		"AB4": "",
+	})
+
+	// A WOF postcode not in the source, ceased during the sync
+	statuses := newOutwardStatuses()
+	statuses.add("AB1 0AD", false, time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	wof := wofdata.NewWOFData(root, &export.Options{})
+	date := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	err := ceasePostalRegions(source, statuses, regionDB, wof, date, "", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		cessation string
+		isCurrent int64
+	}{
+		"AB1": {"2019-06-01", 0},
+		"AB2": {edtf.UNSPECIFIED, 1},
+		"AB3": {edtf.UNSPECIFIED, 1},
+		"AB4": {edtf.UNSPECIFIED, 1},
+	}
+
+	for name, expected := range tests {
+		cessation, isCurrent := readPostalRegion(t, root, regionDB.Regions[name])
+		if cessation != expected.cessation || isCurrent != expected.isCurrent {
+			t.Errorf("%s: expected cessation %s and mz:is_current %d, got %s and %d", name, expected.cessation, expected.isCurrent, cessation, isCurrent)
+		}
+	}
+}
+
+func TestCeasePostalRegionsWithoutUncease(t *testing.T) {
+	source := postcodesource.NewMemorySource(&postcodesource.Record{Postcode: "AB2 0AA"})
+	root, regionDB := writePostalRegions(t, map[string]string{"AB2": "2015-01-01"})
+
+	wof := wofdata.NewWOFData(root, &export.Options{})
+
+	err := ceasePostalRegions(source, newOutwardStatuses(), regionDB, wof, time.Now(), "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cessation, isCurrent := readPostalRegion(t, root, regionDB.Regions["AB2"])
+	if cessation != "2015-01-01" || isCurrent != 0 {
+		t.Errorf("expected AB2 to be left ceased, got cessation %s and mz:is_current %d", cessation, isCurrent)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/codepoint"
+	"github.com/whosonfirst/wof-sync-os-postcodes/osgb"
+)
+
+func buildCodePointDB(path string, ostn15Path string) (*codepoint.CodePointDB, error) {
+	var transformer osgb.Transformer = osgb.NewHelmert()
+
+	if ostn15Path != "" {
+		log.Printf("Loading OSTN15 grid from %s", ostn15Path)
+
+		grid, err := osgb.LoadOSTN15(ostn15Path)
+		if err != nil {
+			return nil, err
+		}
+
+		transformer = grid
+	}
+
+	log.Print("Building Code-Point Open database")
+	db := codepoint.NewCodePointDB(path, transformer)
+
+	err := db.Build()
+	if err != nil {
+		return nil, err
+	}
+	log.Print("Finished building Code-Point Open database")
+
+	return db, nil
+}
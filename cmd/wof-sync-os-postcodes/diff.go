@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/onsdb"
+)
+
+// runDiff implements the `diff` subcommand, which reports the differences
+// between two releases of the ONS Postcode Directory without touching WOF.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var oldCSVPath = fs.String("old-ons-csv-path", "", "The path to the older ONS postcodes CSV, gzipped CSV or ONSPD release zip")
+	var newCSVPath = fs.String("new-ons-csv-path", "", "The path to the newer ONS postcodes CSV, gzipped CSV or ONSPD release zip")
+	var minMove = fs.Float64("min-move", 1, "The distance in metres a postcode has to move to be reported")
+	var outputPath = fs.String("output", "", "The path to write the CSV report to, defaults to stdout")
+	fs.Parse(args)
+
+	if *oldCSVPath == "" || *newCSVPath == "" {
+		log.Fatal("Both -old-ons-csv-path and -new-ons-csv-path are required")
+	}
+
+	log.Print("Building old ONS database")
+	// Invalid rows are left out of the comparison, rather than stopping it
+	oldDB := onsdb.NewONSDB(*oldCSVPath)
+	oldDB.SetInvalidRowPolicy(onsdb.InvalidRowsSkip)
+	err := oldDB.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Print("Building new ONS database")
+	newDB := onsdb.NewONSDB(*newCSVPath)
+	newDB.SetInvalidRowPolicy(onsdb.InvalidRowsSkip)
+	err = newDB.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report := onsdb.Diff(oldDB, newDB, *minMove)
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "" {
+		f, err := os.Create(*outputPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	err = writeDiffReport(out, report)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Diff: %d added, %d removed, %d terminated, %d moved, %d date changes, %d code changes", len(report.Added), len(report.Removed), len(report.Terminated), len(report.Moved), len(report.DateChanges), len(report.CodeChanges))
+}
+
+// writeDiffReport writes one CSV row per change, or per changed field for
+// date and code changes.
+func writeDiffReport(out io.Writer, report *onsdb.DiffReport) error {
+	w := csv.NewWriter(out)
+
+	err := w.Write([]string{"change", "postcode", "field", "old", "new", "distance_m"})
+	if err != nil {
+		return err
+	}
+
+	for _, pc := range report.Added {
+		w.Write([]string{"added", pc.Postcode, "", "", "", ""})
+	}
+
+	for _, pc := range report.Removed {
+		w.Write([]string{"removed", pc.Postcode, "", "", "", ""})
+	}
+
+	for _, pc := range report.Terminated {
+		w.Write([]string{"terminated", pc.Postcode, string(onsdb.FieldCessation), "", pc.Cessation, ""})
+	}
+
+	for _, move := range report.Moved {
+		distance := "unknown"
+		if move.Distance >= 0 {
+			distance = fmt.Sprintf("%.1f", move.Distance)
+		}
+
+		oldLocation := strings.Join([]string{move.Old.Latitude, move.Old.Longitude}, ",")
+		newLocation := strings.Join([]string{move.New.Latitude, move.New.Longitude}, ",")
+
+		w.Write([]string{"moved", move.Postcode, "", oldLocation, newLocation, distance})
+	}
+
+	writeChanges := func(kind string, changes []*onsdb.PostcodeChange) {
+		for _, change := range changes {
+			for _, field := range change.Fields {
+				w.Write([]string{kind, change.Postcode, string(field), change.Old.Value(string(field)), change.New.Value(string(field)), ""})
+			}
+		}
+	}
+
+	writeChanges("date", report.DateChanges)
+	writeChanges("code", report.CodeChanges)
+
+	w.Flush()
+	return w.Error()
+}
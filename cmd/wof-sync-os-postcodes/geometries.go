@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulmach/orb"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/hull"
+	"github.com/whosonfirst/wof-sync-os-postcodes/onsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
+)
+
+// The alternate geometry label for postalregion hulls
+const postalRegionHullLabel = "os-hull"
+
+// runPostalRegionGeometries implements the `postalregion-geometries`
+// subcommand, which writes a polygon around the live unit postcodes of each
+// postalregion as an alternate geometry.
+func runPostalRegionGeometries(args []string) {
+	fs := flag.NewFlagSet("postalregion-geometries", flag.ExitOnError)
+	var onsCSVPath = fs.String("ons-csv-path", "", "The path to the ONS postcodes CSV, gzipped CSV or ONSPD release zip")
+	var onsDate = fs.String("ons-date", "", "The date of the ONS postalcodes CSV")
+	var wofAdminDataPath = fs.String("wof-admin-data-path", "", "The path to the GB admin data directory")
+	var method = fs.String("method", "concave", "How to build the polygons, either concave or convex hulls")
+	var neighbours = fs.Int("neighbours", 10, "The number of neighbouring points to consider when building concave hulls, lower values follow the points more closely")
+	var prefixFilter = fs.String("prefix-filter", "", "Just do work on the postalregions starting with the string")
+	var dryRun = fs.Bool("dry-run", false, "Set to true to do nothing")
+	var ignoreRestrictiveLicence = fs.Bool("ignore-restrictive-licence", false, "Ignore the restrictive license on the Northern Ireland postcodes")
+	fs.Parse(args)
+
+	if *method != "concave" && *method != "convex" {
+		log.Fatalf("Unknown -method %s, expected concave or convex", *method)
+	}
+
+	release, err := time.Parse("2006-01-02", *onsDate)
+	if err != nil {
+		log.Fatalf("Missing or invalid -ons-date flag: %s", err)
+	}
+
+	ctx := context.Background()
+	opts, err := createExportOptions(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Print("Building ONS database")
+	db := onsdb.NewONSDB(*onsCSVPath)
+	db.SetInvalidRowPolicy(onsdb.InvalidRowsSkip)
+	err = db.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Print("Building postalregions database")
+	regionDB := postalregionsdb.NewPostalRegionsDB(*wofAdminDataPath)
+	err = regionDB.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	points, err := livePointsByOutward(db, *ignoreRestrictiveLicence)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wof := wofdata.NewWOFData(*wofAdminDataPath, opts)
+
+	names := make([]string, 0, len(regionDB.Regions))
+	for name := range regionDB.Regions {
+		if strings.HasPrefix(name, *prefixFilter) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var written int
+	var skipped int
+
+	// The country polygons the hulls are clipped to, by ID
+	countries := make(map[int64][]orb.Polygon)
+
+	for _, name := range names {
+		region := regionDB.Regions[name]
+
+		var ring orb.Ring
+		if *method == "convex" {
+			ring = hull.Convex(points[name])
+		} else {
+			ring = hull.Concave(points[name], *neighbours)
+		}
+
+		if ring == nil {
+			log.Printf("Not enough live postcodes to build a polygon for %s (ID %d), skipping", name, region.WofID)
+			skipped++
+			continue
+		}
+
+		land, err := countryPolygons(wof, region, countries)
+		if err != nil {
+			log.Fatalf("Failed to load the countries of %s (ID %d): %s", name, region.WofID, err)
+		}
+
+		geometry, err := clipToLand(ring, land)
+		if errors.Is(err, hull.ErrDegenerate) {
+			log.Printf("Unable to clip the polygon for %s (ID %d) to its countries, leaving it unclipped", name, region.WofID)
+			geometry, err = orb.Polygon{ring}, nil
+		}
+
+		if err != nil {
+			log.Fatalf("Failed to clip the polygon for %s (ID %d): %s", name, region.WofID, err)
+		}
+
+		if geometry == nil {
+			log.Printf("Polygon for %s (ID %d) is outside its countries, skipping", name, region.WofID)
+			skipped++
+			continue
+		}
+
+		changed, err := wof.SetAltGeometry(region.WofID, geometry, postalRegionHullLabel, release, *dryRun)
+		if err != nil {
+			log.Fatalf("Failed to write geometry for %s (ID %d): %s", name, region.WofID, err)
+		}
+
+		if changed {
+			log.Printf("Wrote geometry for postalregion: %s (ID %d)", name, region.WofID)
+			written++
+		}
+	}
+
+	log.Printf("Stats: %d geometries written, %d postalregions without enough postcodes or outside their countries, %d unchanged", written, skipped, len(names)-written-skipped)
+}
+
+// countryPolygons returns the polygons of the countries in the postalregion's
+// hierarchies, loading each country into the cache once.
+func countryPolygons(wof *wofdata.WOFData, region *postalregionsdb.PostalRegion, cache map[int64][]orb.Polygon) ([]orb.Polygon, error) {
+	var polygons []orb.Polygon
+	seen := make(map[int64]bool)
+
+	for _, h := range region.Hierarchy {
+		id := h["country_id"]
+		if id <= 0 || seen[id] {
+			continue
+		}
+
+		seen[id] = true
+
+		country, ok := cache[id]
+		if !ok {
+			geometry, err := wof.Geometry(id)
+			if err != nil {
+				return nil, err
+			}
+
+			switch g := geometry.(type) {
+			case orb.Polygon:
+				country = []orb.Polygon{g}
+			case orb.MultiPolygon:
+				country = g
+			}
+
+			cache[id] = country
+		}
+
+		polygons = append(polygons, country...)
+	}
+
+	return polygons, nil
+}
+
+// clipToLand trims the hull to the polygons, so it doesn't spill out to sea
+// or over a border. The hull is left as it is if there are no polygons, and
+// nil is returned if it's outside all of them.
+func clipToLand(ring orb.Ring, land []orb.Polygon) (orb.Geometry, error) {
+	if len(land) == 0 {
+		return orb.Polygon{ring}, nil
+	}
+
+	var clipped orb.MultiPolygon
+
+	for _, polygon := range land {
+		parts, err := hull.Clip(ring, polygon)
+		if err != nil {
+			return nil, err
+		}
+
+		clipped = append(clipped, parts...)
+	}
+
+	switch len(clipped) {
+	case 0:
+		return nil, nil
+	case 1:
+		return clipped[0], nil
+	}
+
+	return clipped, nil
+}
+
+// livePointsByOutward returns the locations of the live postcodes in the
+// source, keyed by outward code. Northern Ireland postcodes are left out
+// unless ignoreRestrictiveLicence is set, as they're under a more restrictive
+// licence.
+func livePointsByOutward(source postcodesource.PostcodeSource, ignoreRestrictiveLicence bool) (map[string][]orb.Point, error) {
+	points := make(map[string][]orb.Point)
+	mu := sync.Mutex{}
+
+	err := source.Iterate(func(pc *postcodesource.Record) error {
+		if pc.Cessation != "" {
+			return nil
+		}
+
+		point, ok := pc.Point()
+		if !ok {
+			return nil
+		}
+
+		parsed, err := postcodevalidator.Parse(pc.Postcode)
+		if err != nil {
+			return nil
+		}
+
+		if parsed.Area == "BT" && !ignoreRestrictiveLicence {
+			return nil
+		}
+
+		mu.Lock()
+		points[parsed.Outward()] = append(points[parsed.Outward()], point)
+		mu.Unlock()
+
+		return nil
+	})
+
+	return points, err
+}
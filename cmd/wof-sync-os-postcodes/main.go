@@ -6,16 +6,23 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/paulmach/orb"
 	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/wof-sync-os-postcodes/admindata"
 	"github.com/whosonfirst/wof-sync-os-postcodes/onsdb"
 	"github.com/whosonfirst/wof-sync-os-postcodes/pipclient"
 	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
 	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+	"github.com/whosonfirst/wof-sync-os-postcodes/suggest"
 	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
 
 	export "github.com/whosonfirst/go-whosonfirst-export/v2"
@@ -26,14 +33,53 @@ import (
 )
 
 func main() {
-	var onsCSVPath = flag.String("ons-csv-path", "", "The path to the ONS postcodes CSV")
-	var onsDate = flag.String("ons-date", "", "The date of the ONS postalcodes CSV")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+
+		case "postalregion-geometries":
+			runPostalRegionGeometries(os.Args[2:])
+			return
+
+		case "audit-hierarchies":
+			runAuditHierarchies(os.Args[2:])
+			return
+		}
+	}
+
+	var sourceName = flag.String("source", "onspd", "The postcode data to sync against, either onspd for the ONS Postcode Directory or codepoint for Ordnance Survey Code-Point Open")
+	var onsCSVPath = flag.String("ons-csv-path", "", "The path to the ONS postcodes CSV, gzipped CSV or ONSPD release zip")
+	var onsChecksum = flag.String("ons-sha256", "", "The expected SHA-256 checksum of the file at -ons-csv-path, checked before loading it")
+	var onsSchema = flag.String("ons-schema", "", "The ONSPD column schema to read the CSV with, detected from the header row if not set")
+	var onsSnapshotPath = flag.String("ons-snapshot-path", "", "The path to a snapshot of the ONS database, loaded instead of the CSV if it was built from the same file, otherwise written after the CSV is loaded")
+	var onsInvalidRows = flag.String("ons-invalid-rows", string(onsdb.InvalidRowsFail), "What to do with invalid rows in the ONS data, either fail to refuse to start or skip to leave them out")
+	var onsReportPath = flag.String("ons-report-path", "", "The path to write a CSV report of invalid rows in the ONS data to")
+	var codePointPath = flag.String("codepoint-path", "", "The path to the Code-Point Open zip, or a directory of its CSVs")
+	var ostn15Path = flag.String("ostn15-path", "", "The path to the OSTN15_OSGM15_DataFile.txt grid, used to convert Code-Point Open coordinates more accurately than the default Helmert transformation")
+	var onsDate = flag.String("ons-date", "", "The date of the ONS postalcodes CSV, or of the Code-Point Open release if syncing against that")
 	var wofPostalcodesPath = flag.String("wof-postalcodes-path", "", "The path to the WOF postalcodes data")
 	var dryRunFlag = flag.Bool("dry-run", false, "Set to true to do nothing")
 	var noCreate = flag.Bool("no-create", false, "Set to disable the creation of new any features")
 	var noUpdate = flag.Bool("no-update", false, "Set to disable the updating of existing features")
 	var wofAdminDataPath = flag.String("wof-admin-data-path", "", "The path to the GB admin data directory")
+	var wofAdminSQLitePath = flag.String("wof-admin-sqlite-path", "", "The path to a WOF SQLite distribution of the GB admin data, read instead of -wof-admin-data-path when set")
 	var prefixFilter = flag.String("prefix-filter", "", "Just do work on the postcode starting with the string")
+	var propertyMappingPath = flag.String("property-mapping-path", "", "The path to a JSON file mapping ONS columns to WOF properties, the default mapping is used if not set")
+	var renameNonCanonical = flag.Bool("rename-non-canonical", false, "Rename postcodes whose names aren't in the canonical ONS form, e.g. sw1a1aa to SW1A 1AA, keeping the old name as a variant")
+	var suggestionsPath = flag.String("suggestions-path", "", "The path to write a CSV of live postcodes which ceased or deprecated postcodes may have been meant to be, for review")
+	var suggestionMaxEdits = flag.Int("suggestion-max-edits", 2, "The most characters a suggested postcode can differ by")
+	var suggestionMaxDistance = flag.Float64("suggestion-max-distance", 1000, "The furthest in metres a suggested postcode can be from the WOF record")
+	var createPostalRegionsFlag = flag.Bool("create-postalregions", false, "Create postalregion features in the admin data for outward codes with live postcodes but no postalregion")
+	var ceasePostalRegionsFlag = flag.Bool("cease-postalregions", false, "Cease postalregions in the admin data once all their postcodes are ceased")
+	var unceasePostalRegionsFlag = flag.Bool("uncease-postalregions", false, "Make ceased postalregions with live postcodes current again, rather than just reporting them, when -cease-postalregions is set")
+	var syncPostalSectorsFlag = flag.Bool("sync-postalsectors", false, "Create and update postcode sector features, e.g. SW1A 1, in the admin data and attach unit postcodes to them")
+	var parentPlacetypes = flag.String("parent-placetypes", strings.Join(pipclient.DefaultPlacetypes, ","), "The placetypes postcodes can be parented by, in order of precedence, e.g. neighbourhood,borough,locality,localadmin,county,region")
+	var pipCacheCellSize = flag.Float64("pip-cache-cell-size", 0.001, "The size in degrees of the grid cells PIP results are cached for, or 0 to disable the cache")
+	var pipCacheStrict = flag.Bool("pip-cache-strict", false, "Recheck the polygons containing each postcode on a PIP cache hit, in case one crosses the cell without covering its corners")
+	var nearestParentDistance = flag.Float64("nearest-parent-distance", 500, "How far in metres to look for the nearest polygon to parent postcodes outside all of them by, or 0 to leave them without a parent")
+	var parentReportPath = flag.String("parent-report-path", "", "The path to write a CSV of postcodes parented by the nearest polygon, and those left without a parent")
 	var ignoreRestrictiveLicenceFlag = flag.Bool("ignore-restrictive-licence", false, "Ignore the restrictive license on the Northern Ireland postcodes")
 	flag.Parse()
 
@@ -51,64 +97,258 @@ func main() {
 	}
 
 	wof := wofdata.NewWOFData(*wofPostalcodesPath, opts)
+	wof.SetRenameNonCanonical(*renameNonCanonical)
+
+	propertyMapping := wofdata.DefaultPropertyMapping()
+	if *propertyMappingPath != "" {
+		propertyMapping, err = wofdata.LoadPropertyMapping(*propertyMappingPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		wof.SetPropertyMapping(propertyMapping)
+	}
 
 	onsDBDate, err := time.Parse("2006-01-02", *onsDate)
 	if err != nil {
 		log.Fatalf("Missing or invalid -ons-date flag - make sure you explicitly set the date of the ONS database you're syncing against: %s", err)
 	}
 
-	log.Print("Building ONS database")
-	db := onsdb.NewONSDB(*onsCSVPath)
-	err = db.Build()
+	var source postcodesource.PostcodeSource
+
+	switch *sourceName {
+	case "onspd":
+		log.Print("Building ONS database")
+		db := onsdb.NewONSDB(*onsCSVPath)
+		db.SetExtraColumns(propertyMapping.Columns())
+
+		invalidRowPolicy, err := onsdb.ParseInvalidRowPolicy(*onsInvalidRows)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		db.SetInvalidRowPolicy(invalidRowPolicy)
+
+		if *onsChecksum != "" {
+			err = db.VerifyChecksum(*onsChecksum)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if *onsSchema != "" {
+			schema, err := onsdb.GetSchema(*onsSchema)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			db.SetSchema(schema)
+		}
+
+		if *onsSnapshotPath != "" {
+			err = db.BuildWithSnapshot(*onsSnapshotPath)
+		} else {
+			err = db.Build()
+		}
+
+		if *onsReportPath != "" {
+			reportErr := writeValidationReport(*onsReportPath, db.Report())
+			if reportErr != nil {
+				log.Fatal(reportErr)
+			}
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Print("Finished building ONS database")
+
+		source = db
+
+	case "codepoint":
+		source, err = buildCodePointDB(*codePointPath, *ostn15Path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		log.Fatalf("Unknown -source %s, expected onspd or codepoint", *sourceName)
+	}
+
+	metadata := source.Metadata()
+	log.Printf("Syncing against %d postcodes from %s (%s), licensed %s", metadata.Count, metadata.Name, metadata.Path, metadata.Licence)
+	wof.SetSourceColumns(metadata.Columns)
+
+	var suggester *suggest.Suggester
+	var review *suggest.ReviewWriter
+
+	if *suggestionsPath != "" {
+		suggester, err = suggest.NewSuggester(source, *suggestionMaxEdits, *suggestionMaxDistance)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		f, err := os.Create(*suggestionsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		review, err = suggest.NewReviewWriter(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// writeSuggestions records candidates for a postcode being ceased or
+	// deprecated, if suggestions are enabled
+	writeSuggestions := func(f []byte, id string, postcode string, action string) error {
+		if suggester == nil {
+			return nil
+		}
+
+		point, hasPoint := featurePoint(f)
+
+		suggestions := suggester.Suggest(postcode, point, hasPoint)
+		if len(suggestions) == 0 {
+			return nil
+		}
+
+		log.Printf("Found %d suggestions for %s postcode %s (ID %s)", len(suggestions), action, postcode, id)
+		return review.Write(id, postcode, action, suggestions)
+	}
+
+	// Only index the polygons postcodes can be parented by, along with the
+	// counties and regions that postalregions are
+	parents := strings.Split(*parentPlacetypes, ",")
+	indexPlacetypes := slices.Clone(parents)
+	for _, pt := range []string{"county", "region"} {
+		if !slices.Contains(indexPlacetypes, pt) {
+			indexPlacetypes = append(indexPlacetypes, pt)
+		}
+	}
+
+	indexer, err := pipclient.NewIndexer(ctx, indexPlacetypes)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Print("Finished building ONS database")
 
-	log.Print("Building postalregions database")
-	regionDB := postalregionsdb.NewPostalRegionsDB(*wofAdminDataPath)
-	err = regionDB.Build()
+	// The admin data can be read from a SQLite distribution, but new and
+	// changed features are still written to the repo
+	adminPath := *wofAdminDataPath
+	if *wofAdminSQLitePath != "" {
+		adminPath = *wofAdminSQLitePath
+	}
+
+	if adminPath == "" {
+		log.Fatal("Either -wof-admin-data-path or -wof-admin-sqlite-path is required")
+	}
+
+	if *wofAdminDataPath == "" && (*createPostalRegionsFlag || *ceasePostalRegionsFlag || *syncPostalSectorsFlag) {
+		log.Fatal("-wof-admin-data-path is required to write postalregions and postal sectors")
+	}
+
+	// Read the admin data once for both the postalregions database and the
+	// PIP index, as it's far too big to walk twice
+	log.Print("Loading admin data")
+	regionDB := postalregionsdb.NewPostalRegionsDB(adminPath)
+	err = admindata.Load(ctx, adminPath, admindata.Merge(regionDB.Filter(), indexer.Filter()), regionDB.AddFeature, indexer.AddFeature)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Print("Finished building postalregions database")
+	log.Print("Finished loading admin data")
 
-	pip, err := pipclient.NewPIPClient(ctx, *wofAdminDataPath)
+	pip, err := indexer.Client(ctx, adminPath)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer pip.Close()
+
+	err = pip.SetPlacetypes(parents)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *pipCacheCellSize > 0 {
+		err = pip.EnableCache(*pipCacheCellSize, *pipCacheStrict)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *nearestParentDistance > 0 {
+		err = pip.EnableNearestFallback(*nearestParentDistance)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var parentReport *pipclient.ParentReport
+
+	if *parentReportPath != "" {
+		f, err := os.Create(*parentReportPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		parentReport, err = pipclient.NewParentReport(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		pip.SetParentReport(parentReport)
+	}
+
+	adminWOF := wofdata.NewWOFData(*wofAdminDataPath, opts)
+
+	if *createPostalRegionsFlag {
+		log.Print("Creating missing postalregions")
+		err = createPostalRegions(ctx, source, regionDB, adminWOF, pip, *prefixFilter, dryRun, ignoreRestrictiveLicence)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Print("Finished creating missing postalregions")
+	}
+
+	if *syncPostalSectorsFlag {
+		log.Print("Syncing postal sectors")
+		err = syncPostalSectors(ctx, source, regionDB, adminWOF, *prefixFilter, dryRun, ignoreRestrictiveLicence)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Print("Finished syncing postal sectors")
+	}
+
+	// The postcodes in WOF but not in the ONS data, which get ceased, so
+	// postalregions can be ceased once they have no live postcodes
+	statuses := newOutwardStatuses()
 
 	seenPostcodes := make(map[string]bool)
 	seenPostcodesMutex := sync.RWMutex{}
 
 	var ceasedCounter uint64
 	var deprecatedCounter uint64
+	var skippedCounter uint64
+	var nonCanonicalCounter uint64
 	var updatedCounter uint64
 	var newCounter uint64
 
-	cb := func(f []byte) error {
-		postcode := ""
-		nameResult := gjson.GetBytes(f, "properties.wof:name")
-		if nameResult.Exists() {
-			postcode = nameResult.String()
-		}
-
-		if postcode == "" {
-			return errors.New("name not found on existing record")
-		}
-
-		// Track which postcodes we've seen, so we can make new ones later on
-		seenPostcodesMutex.Lock()
-		seenPostcodes[postcode] = true
-		seenPostcodesMutex.Unlock()
+	// Records whose names only became valid by swapping O/0 or I/1, which
+	// wait until every correctly named record has been seen
+	var corrected [][]byte
+	correctedMutex := sync.Mutex{}
 
+	// syncFeature updates, ceases or deprecates a WOF postcode. duplicate is
+	// set for corrected names which another record already has.
+	syncFeature := func(f []byte, postcode string, canonical string, normaliseErr error, duplicate bool) error {
 		// We're doing updating existing postcodes in this pass, so skip the rest
 		if *noUpdate {
 			return nil
 		}
 
 		// Check whether the postcode match the prefix-filter flag, and skip if not
-		if prefixFilter != nil && !strings.HasPrefix(postcode, *prefixFilter) {
+		if prefixFilter != nil && !strings.HasPrefix(canonical, *prefixFilter) {
 			return nil
 		}
 
@@ -133,18 +373,59 @@ func main() {
 			return nil
 		}
 
-		postcodeData, err := db.GetPostcodeData(postcode)
+		if normaliseErr == nil && canonical != postcode {
+			log.Printf("Postcode name isn't canonical: %s should be %s (ID %s)", postcode, canonical, id)
+			atomic.AddUint64(&nonCanonicalCounter, 1)
+		}
+
+		// Merging a typo onto the correct record's postcode would leave two
+		// records for it, so the typo goes instead
+		if duplicate {
+			changed, err := wof.DeprecateFeature(f, dryRun)
+			if changed {
+				log.Printf("Deprecated postcode duplicating %s once corrected: %s (ID %s)", canonical, postcode, id)
+				atomic.AddUint64(&deprecatedCounter, 1)
+
+				err = errors.Join(err, writeSuggestions(f, id, postcode, "deprecated"))
+			}
+
+			return err
+		}
+
+		postcodeData, err := source.Lookup(canonical)
 		if err != nil {
 			return err
 		}
 
 		if postcodeData == nil {
-			// If we can't find the postcode in the database but it's valid, then cease it
-			if postcodevalidator.Validate(postcode) {
+			if ceasable(postcode, normaliseErr) {
+				// Special and overseas territory postcodes are never in the ONS
+				// data, so their absence doesn't mean anything. Historic
+				// postcodes which don't parse are all geographic.
+				parsed, err := postcodevalidator.Parse(canonical)
+				if err == nil && !listedByONS(parsed.Category) {
+					log.Printf("Skipping %s postcode not in ONS DB: %s (ID %s)", parsed.Category, postcode, id)
+					atomic.AddUint64(&skippedCounter, 1)
+					return nil
+				}
+
+				// Sources such as Code-Point Open leave out whole areas, so
+				// postcodes in them are missing rather than terminated
+				if !source.Covers(canonical) {
+					log.Printf("Skipping postcode outside the areas %s covers: %s (ID %s)", metadata.Name, postcode, id)
+					atomic.AddUint64(&skippedCounter, 1)
+					return nil
+				}
+
+				// If we can't find the postcode in the database but it's valid, then cease it
 				changed, err := wof.CeaseFeature(f, onsDBDate, dryRun)
+				statuses.add(canonical, false, featureCessation(f, changed, onsDBDate))
+
 				if changed {
 					log.Printf("Ceased postcode not in ONS DB: %s (ID %s)", postcode, id)
 					atomic.AddUint64(&ceasedCounter, 1)
+
+					err = errors.Join(err, writeSuggestions(f, id, postcode, "ceased"))
 				}
 
 				if err != nil {
@@ -157,8 +438,10 @@ func main() {
 			// If it's not valid, then deprecate it, as it probably should never have existed
 			changed, err := wof.DeprecateFeature(f, dryRun)
 			if changed {
-				log.Printf("Deprecated invalid postcode: %s (ID %s)", postcode, id)
+				log.Printf("Deprecated invalid postcode: %s (ID %s): %s", postcode, id, normaliseErr)
 				atomic.AddUint64(&deprecatedCounter, 1)
+
+				err = errors.Join(err, writeSuggestions(f, id, postcode, "deprecated"))
 			}
 
 			if err != nil {
@@ -181,6 +464,39 @@ func main() {
 		return nil
 	}
 
+	cb := func(f []byte) error {
+		postcode := ""
+		nameResult := gjson.GetBytes(f, "properties.wof:name")
+		if nameResult.Exists() {
+			postcode = nameResult.String()
+		}
+
+		if postcode == "" {
+			return errors.New("name not found on existing record")
+		}
+
+		// Match on the canonical form of the name, so that records named
+		// sw1a1aa or SW1A  1AA are still found
+		canonical, normaliseErr := postcodevalidator.Normalise(postcode)
+		if normaliseErr != nil {
+			canonical = postcode
+		}
+
+		if normaliseErr == nil && postcodevalidator.Corrected(postcode) {
+			correctedMutex.Lock()
+			corrected = append(corrected, f)
+			correctedMutex.Unlock()
+			return nil
+		}
+
+		// Track which postcodes we've seen, so we can make new ones later on
+		seenPostcodesMutex.Lock()
+		seenPostcodes[canonical] = true
+		seenPostcodesMutex.Unlock()
+
+		return syncFeature(f, postcode, canonical, normaliseErr, false)
+	}
+
 	log.Print("Walking over WOF postcodes")
 
 	err = wof.Iterate(cb)
@@ -188,12 +504,33 @@ func main() {
 		log.Fatalf("Iteration failed: %s", err)
 	}
 
+	// In ID order, so the same record keeps a corrected postcode each run
+	sort.Slice(corrected, func(i, j int) bool {
+		return gjson.GetBytes(corrected[i], "id").Int() < gjson.GetBytes(corrected[j], "id").Int()
+	})
+
+	for _, f := range corrected {
+		postcode := gjson.GetBytes(f, "properties.wof:name").String()
+		canonical, err := postcodevalidator.Normalise(postcode)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		duplicate := seenPostcodes[canonical]
+		seenPostcodes[canonical] = true
+
+		err = syncFeature(f, postcode, canonical, nil, duplicate)
+		if err != nil {
+			log.Fatalf("Syncing corrected postcodes failed: %s", err)
+		}
+	}
+
 	if *noCreate {
 		log.Printf("no-create flag enabled, so skipping new postcodes")
 	} else {
 		log.Printf("Seen %d postcodes, now checking for new postcodes", len(seenPostcodes))
 
-		onsCB := func(pc *onsdb.PostcodeData) error {
+		onsCB := func(pc *postcodesource.Record) error {
 			// Skip if we've already seen this postcode
 			if seenPostcodes[pc.Postcode] {
 				return nil
@@ -213,7 +550,34 @@ func main() {
 			return wof.NewFeature(ctx, pc, regionDB, pip, dryRun)
 		}
 
-		err = db.Iterate(onsCB)
+		err = source.Iterate(onsCB)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *ceasePostalRegionsFlag {
+		if *noUpdate {
+			log.Print("no-update flag enabled, so skipping ceasing postalregions")
+		} else {
+			log.Print("Ceasing postalregions without live postcodes")
+			err = ceasePostalRegions(source, statuses, regionDB, adminWOF, onsDBDate, *prefixFilter, *unceasePostalRegionsFlag, dryRun)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Print("Finished ceasing postalregions")
+		}
+	}
+
+	if review != nil {
+		err = review.Flush()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if parentReport != nil {
+		err = parentReport.Flush()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -221,13 +585,20 @@ func main() {
 
 	ceased := atomic.LoadUint64(&ceasedCounter)
 	deprecated := atomic.LoadUint64(&deprecatedCounter)
+	skipped := atomic.LoadUint64(&skippedCounter)
 	updated := atomic.LoadUint64(&updatedCounter)
 	new := atomic.LoadUint64(&newCounter)
+	nonCanonical := atomic.LoadUint64(&nonCanonicalCounter)
 
-	log.Printf("Stats: %d not found and ceased, %d found invalid then deprecated, %d updated, %d new", ceased, deprecated, updated, new)
+	log.Printf("Stats: %d not found and ceased, %d found invalid then deprecated, %d special, overseas or uncovered skipped, %d updated, %d new, %d with non-canonical names", ceased, deprecated, skipped, updated, new, nonCanonical)
+
+	if *pipCacheCellSize > 0 {
+		cacheStats := pip.CacheStats()
+		log.Printf("PIP cache stats: %d hits, %d misses, %d boundary hits rechecked, %d boundary cells not cached", cacheStats.Hits, cacheStats.Misses, cacheStats.BoundaryHits, cacheStats.BoundaryCells)
+	}
 }
 
-func shouldCreateNewPostcode(pc *onsdb.PostcodeData) bool {
+func shouldCreateNewPostcode(pc *postcodesource.Record) bool {
 	// Channel Islands
 	if pc.CountryCode == "L93000001" {
 		return false
@@ -241,6 +612,66 @@ func shouldCreateNewPostcode(pc *onsdb.PostcodeData) bool {
 	return true
 }
 
+// featurePoint returns the location of a WOF feature, if it has one other
+// than null island.
+func featurePoint(f []byte) (orb.Point, bool) {
+	lat := gjson.GetBytes(f, "properties.geom:latitude").Float()
+	lon := gjson.GetBytes(f, "properties.geom:longitude").Float()
+
+	if lat == 0 && lon == 0 {
+		return orb.Point{}, false
+	}
+
+	return orb.Point{lon, lat}, true
+}
+
+// featureCessation returns when a WOF postcode was ceased: on date if it's
+// just been ceased, otherwise its existing cessation date, which is zero if it
+// isn't a plain date.
+func featureCessation(f []byte, justCeased bool, date time.Time) time.Time {
+	if justCeased {
+		return date
+	}
+
+	cessation, err := time.Parse("2006-01-02", gjson.GetBytes(f, "properties.edtf:cessation").String())
+	if err != nil {
+		return time.Time{}
+	}
+
+	return cessation
+}
+
+// ceasable returns whether a postcode missing from the source is a real one
+// which has been terminated, rather than one which should never have existed.
+// Terminated postcodes can break today's rules, e.g. W1M 1AA, which Normalise
+// rejects, so the looser Validate is enough.
+func ceasable(postcode string, normaliseErr error) bool {
+	return normaliseErr == nil || postcodevalidator.Validate(postcode)
+}
+
+// listedByONS returns whether postcodes of the category appear in the ONS
+// data. Geographic and non-geographic postcodes are ceased when they drop out
+// of it, but the rest are left alone.
+func listedByONS(category postcodevalidator.Category) bool {
+	switch category {
+	case postcodevalidator.CategoryGeographic, postcodevalidator.CategoryNonGeographic:
+		return true
+	}
+
+	return false
+}
+
+func writeValidationReport(path string, report *onsdb.ValidationReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Printf("Writing report of %d invalid rows in the ONS data to %s", report.InvalidRows(), path)
+	return report.Write(f)
+}
+
 func createExportOptions(ctx context.Context) (*export.Options, error) {
 	uri := "proxy:///?provider=whosonfirst://&minimum=100&pool=memory%3A%2F%2F"
 	cl, _ := id.NewProviderWithURI(ctx, uri)
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+)
+
+func TestCeasable(t *testing.T) {
+	tests := map[string]bool{
+		"SW1A 1AA": true,
+		"sw1a1aa":  true,
+		// The old W1M district breaks today's letter rules
+		"W1M 1AA":  true,
+		"GIR 0AA":  true,
+		"SW1A 1A":  false,
+		"NOT A PC": false,
+	}
+
+	for postcode, expected := range tests {
+		_, err := postcodevalidator.Normalise(postcode)
+
+		if got := ceasable(postcode, err); got != expected {
+			t.Errorf("ceasable(%s) = %t, want %t", postcode, got, expected)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/pipclient"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
+)
+
+// createPostalRegions creates a postalregion for every outward code with live
+// postcodes but no postalregion, registering them in the PostalRegionsDB so
+// the postcodes can be attached to them.
+func createPostalRegions(ctx context.Context, source postcodesource.PostcodeSource, regionDB *postalregionsdb.PostalRegionsDB, wof *wofdata.WOFData, pip *pipclient.PIPClient, prefixFilter string, dryRun bool, ignoreRestrictiveLicence bool) error {
+	// The locations of Northern Ireland postcodes are under a more
+	// restrictive licence, so they're left out and nothing's derived from them
+	points, err := livePointsByOutward(source, ignoreRestrictiveLicence)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for outward := range points {
+		if regionDB.Regions[outward] != nil || !strings.HasPrefix(outward, prefixFilter) {
+			continue
+		}
+
+		missing = append(missing, outward)
+	}
+
+	sort.Strings(missing)
+
+	var created int
+
+	for _, outward := range missing {
+		centroid, _ := planar.CentroidArea(orb.MultiPoint(points[outward]))
+
+		region, err := wof.NewPostalRegion(ctx, outward, centroid, pip, dryRun)
+		if err != nil {
+			return err
+		}
+
+		// Nothing's written in a dry run, so there's nothing to attach postcodes to
+		if region == nil {
+			log.Printf("Would create postalregion: %s", outward)
+			continue
+		}
+
+		log.Printf("Created postalregion: %s (ID %d)", outward, region.WofID)
+		created++
+
+		regionDB.AddRegion(region)
+	}
+
+	if dryRun {
+		log.Printf("Would create %d postalregions", len(missing))
+	} else {
+		log.Printf("Created %d postalregions", created)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	export "github.com/whosonfirst/go-whosonfirst-export/v2"
+	uri "github.com/whosonfirst/go-whosonfirst-uri"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/pipclient"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
+)
+
+// testIDProvider hands out IDs in sequence, rather than asking the WOF
+// service for them.
+type testIDProvider struct {
+	next int64
+}
+
+func (p *testIDProvider) NewID(ctx context.Context) (int64, error) {
+	p.next++
+	return p.next, nil
+}
+
+func TestCreatePostalRegions(t *testing.T) {
+	source := postcodesource.NewMemorySource(
+		&postcodesource.Record{Postcode: "AB1 0AA", Latitude: "57.1", Longitude: "-2.2"},
+		&postcodesource.Record{Postcode: "AB1 0AB", Latitude: "57.2", Longitude: "-2.2"},
+		&postcodesource.Record{Postcode: "AB1 0AD", Latitude: "58.0", Longitude: "-3.0", Cessation: "201901"},
+		&postcodesource.Record{Postcode: "AB2 0AA", Latitude: "57.1", Longitude: "-2.1"},
+		&postcodesource.Record{Postcode: "AB3 0AA", Latitude: "57.1", Longitude: "-2.1", Cessation: "201901"},
+		&postcodesource.Record{Postcode: "BT1 1AA", Latitude: "54.6", Longitude: "-5.9"},
+	)
+
+	tests := []struct {
+		ignoreRestrictiveLicence bool
+		created                  []string
+		skipped                  []string
+	}{
+		{false, []string{"AB1"}, []string{"AB3", "BT1"}},
+		{true, []string{"AB1", "BT1"}, []string{"AB3"}},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		root := t.TempDir()
+
+		regionDB := postalregionsdb.NewPostalRegionsDB(root)
+		regionDB.AddRegion(&postalregionsdb.PostalRegion{Name: "AB2", WofID: 1})
+
+		pip, err := pipclient.NewPIPClient(ctx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opts, err := export.NewDefaultOptionsWithProvider(ctx, &testIDProvider{next: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wof := wofdata.NewWOFData(root, opts)
+
+		err = createPostalRegions(ctx, source, regionDB, wof, pip, "", false, test.ignoreRestrictiveLicence)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range test.created {
+			region := regionDB.Regions[name]
+			if region == nil {
+				t.Errorf("ignore licence %t: expected %s to be created", test.ignoreRestrictiveLicence, name)
+				continue
+			}
+
+			body, err := os.ReadFile(mustPath(t, root, region.WofID))
+			if err != nil {
+				t.Fatalf("expected %s to be written: %v", name, err)
+			}
+
+			if placetype := gjson.GetBytes(body, "properties.wof:placetype").String(); placetype != "postalregion" {
+				t.Errorf("expected %s to be a postalregion, got %s", name, placetype)
+			}
+
+			if postalRegionID := region.Hierarchy[0]["postalregion_id"]; postalRegionID != region.WofID {
+				t.Errorf("expected %s's hierarchy to include itself, got %v", name, region.Hierarchy)
+			}
+
+			if saved := gjson.GetBytes(body, "properties.wof:hierarchy.0.postalregion_id").Int(); saved != region.WofID {
+				t.Errorf("expected %s's saved hierarchy to include itself, got %s", name, gjson.GetBytes(body, "properties.wof:hierarchy").Raw)
+			}
+		}
+
+		for _, name := range test.skipped {
+			if regionDB.Regions[name] != nil {
+				t.Errorf("ignore licence %t: expected %s not to be created", test.ignoreRestrictiveLicence, name)
+			}
+		}
+
+		// The centroid of AB1's live postcodes only
+		body, err := os.ReadFile(mustPath(t, root, regionDB.Regions["AB1"].WofID))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		coords := gjson.GetBytes(body, "geometry.coordinates").Array()
+		if len(coords) != 2 || math.Abs(coords[0].Float()+2.2) > 1e-9 || math.Abs(coords[1].Float()-57.15) > 1e-9 {
+			t.Errorf("expected AB1 at the centroid of its live postcodes, got %v", coords)
+		}
+	}
+}
+
+func TestCreatePostalRegionsDryRun(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	source := postcodesource.NewMemorySource(&postcodesource.Record{Postcode: "AB1 0AA", Latitude: "57.1", Longitude: "-2.2"})
+	regionDB := postalregionsdb.NewPostalRegionsDB(root)
+
+	pip, err := pipclient.NewPIPClient(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := export.NewDefaultOptionsWithProvider(ctx, &testIDProvider{next: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = createPostalRegions(ctx, source, regionDB, wofdata.NewWOFData(root, opts), pip, "", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if region := regionDB.Regions["AB1"]; region != nil {
+		t.Errorf("expected nothing registered in a dry run, got %+v", region)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected nothing written in a dry run, got %d entries", len(entries))
+	}
+}
+
+func mustPath(t *testing.T, root string, id int64) string {
+	path, err := uri.Id2AbsPath(root, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalsectors"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/wofdata"
+)
+
+// syncPostalSectors creates and updates a feature for every postcode sector
+// in the source, registering new ones in the PostalRegionsDB so the unit
+// postcodes can be attached to them.
+func syncPostalSectors(ctx context.Context, source postcodesource.PostcodeSource, regionDB *postalregionsdb.PostalRegionsDB, wof *wofdata.WOFData, prefixFilter string, dryRun bool, ignoreRestrictiveLicence bool) error {
+	sectors, err := postalsectors.Group(source, ignoreRestrictiveLicence)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(sectors))
+	for name := range sectors {
+		if strings.HasPrefix(name, prefixFilter) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var created int
+	var updated int
+
+	for _, name := range names {
+		sector := sectors[name]
+		existing := regionDB.Sectors[name]
+		region := regionDB.Regions[sector.Outward]
+
+		if region == nil {
+			log.Printf("Unable to find parent postalregion for sector %s, creating it without a hierarchy", name)
+		}
+
+		synced, changed, err := wof.SyncSector(ctx, sector, existing, region, regionDB, dryRun)
+		if err != nil {
+			return err
+		}
+
+		if !changed {
+			continue
+		}
+
+		if existing != nil {
+			log.Printf("Updated postal sector: %s (ID %d)", name, existing.WofID)
+			updated++
+			continue
+		}
+
+		if dryRun {
+			log.Printf("Would create postal sector: %s", name)
+		} else {
+			log.Printf("Created postal sector: %s (ID %d)", name, synced.WofID)
+		}
+
+		created++
+
+		// Nothing's written in a dry run, so there's nothing to attach units to
+		if synced != nil {
+			regionDB.AddSector(synced)
+		}
+	}
+
+	log.Printf("Postal sector stats: %d created, %d updated, %d unchanged", created, updated, len(names)-created-updated)
+
+	return nil
+}
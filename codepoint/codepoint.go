@@ -0,0 +1,197 @@
+package codepoint
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/smartystreets/scanners/csv"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/osgb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+)
+
+// The columns of the Code-Point Open CSVs, which have no header row
+const (
+	columnPostcode = iota
+	columnPositionalQuality
+	columnEastings
+	columnNorthings
+	columnCountry
+	columnNHSRegionalHA
+	columnNHSHA
+	columnCounty
+	columnDistrict
+	columnWard
+	columnCount
+)
+
+// The Code-Point Open quality indicator for postcodes with no coordinates
+const noCoordinatesQuality = "90"
+
+// columns are the keys Code-Point Open has values for, which leaves out the
+// ONSPD dates and region.
+var columns = []string{
+	postcodesource.KeyPostcode,
+	postcodesource.KeyLatitude,
+	postcodesource.KeyLongitude,
+	postcodesource.KeyCountry,
+	postcodesource.KeyCounty,
+	postcodesource.KeyDistrict,
+	postcodesource.KeyPositionalQuality,
+	"osward",
+}
+
+// CodePointDB holds the postcodes from a Code-Point Open release, mapped on to
+// the same Records as the ONS Postcode Directory.
+type CodePointDB struct {
+	data map[string]*postcodesource.Record
+	path string
+	// areas are the postcode areas in the data, as Code-Point Open leaves
+	// out Northern Ireland, the Channel Islands and the Isle of Man
+	areas       map[string]bool
+	transformer osgb.Transformer
+}
+
+// NewCodePointDB creates a new CodePointDB for the data at path, which can be
+// the codepo_gb.zip archive, a directory of the per-area CSVs or a single CSV.
+// The transformer converts the National Grid coordinates to WGS84.
+func NewCodePointDB(path string, transformer osgb.Transformer) *CodePointDB {
+	return &CodePointDB{
+		data:        make(map[string]*postcodesource.Record),
+		areas:       make(map[string]bool),
+		path:        path,
+		transformer: transformer,
+	}
+}
+
+// Build loads the Code-Point Open data into memory.
+func (db *CodePointDB) Build() error {
+	files, err := openFiles(db.path)
+	if err != nil {
+		return err
+	}
+	defer files.Close()
+
+	for _, f := range files.files {
+		err := db.load(f.reader)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+	}
+
+	log.Printf("Loaded %d postcodes from %d Code-Point Open files", len(db.data), len(files.files))
+
+	return nil
+}
+
+func (db *CodePointDB) load(r io.Reader) error {
+	scanner := csv.NewScanner(r)
+
+	row := 0
+
+	for scanner.Scan() {
+		row++
+
+		pcData, err := db.parse(scanner.Record())
+		if err != nil {
+			return fmt.Errorf("row %d: %w", row, err)
+		}
+
+		db.data[pcData.Postcode] = pcData
+		db.areas[postcodesource.Area(pcData.Postcode)] = true
+	}
+
+	return scanner.Error()
+}
+
+func (db *CodePointDB) parse(record []string) (*postcodesource.Record, error) {
+	if len(record) != columnCount {
+		return nil, fmt.Errorf("expected %d columns, found %d", columnCount, len(record))
+	}
+
+	// Code-Point Open pads postcodes to a fixed width, e.g. AB101AA and AB1 0AA
+	postcode, err := postcodevalidator.Normalise(record[columnPostcode])
+	if err != nil {
+		return nil, err
+	}
+
+	quality := record[columnPositionalQuality]
+
+	pcData := &postcodesource.Record{
+		Postcode:          postcode,
+		Latitude:          postcodesource.NoLocationLatitude,
+		Longitude:         postcodesource.NoLocationLongitude,
+		CountryCode:       record[columnCountry],
+		CountyCode:        record[columnCounty],
+		DistrictCode:      record[columnDistrict],
+		PositionalQuality: positionalQuality(quality),
+		Extra: map[string]string{
+			"osward": record[columnWard],
+		},
+	}
+
+	if quality == noCoordinatesQuality {
+		return pcData, nil
+	}
+
+	easting, err := strconv.ParseFloat(record[columnEastings], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid easting for %s: %w", postcode, err)
+	}
+
+	northing, err := strconv.ParseFloat(record[columnNorthings], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid northing for %s: %w", postcode, err)
+	}
+
+	point, err := db.transformer.ToWGS84(easting, northing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert coordinates for %s: %w", postcode, err)
+	}
+
+	pcData.Latitude = strconv.FormatFloat(point.Lat(), 'f', 6, 64)
+	pcData.Longitude = strconv.FormatFloat(point.Lon(), 'f', 6, 64)
+
+	return pcData, nil
+}
+
+// positionalQuality maps the Code-Point Open quality indicator, which runs
+// from 10 to 90, on to the ONS one, which runs from 1 to 9 with the same
+// meanings.
+func positionalQuality(s string) string {
+	if len(s) == 2 && s[1] == '0' {
+		return s[:1]
+	}
+
+	return s
+}
+
+// Lookup returns the Record for the postcode provided
+func (db *CodePointDB) Lookup(postcode string) (*postcodesource.Record, error) {
+	return db.data[postcode], nil
+}
+
+// Metadata describes the Code-Point Open release loaded into the CodePointDB.
+func (db *CodePointDB) Metadata() *postcodesource.Metadata {
+	return &postcodesource.Metadata{
+		Name:    "Code-Point Open",
+		Path:    db.path,
+		Licence: "OGL-UK-3.0",
+		Count:   len(db.data),
+		Columns: columns,
+	}
+}
+
+// Covers returns whether the postcode's area is in the Code-Point Open data.
+func (db *CodePointDB) Covers(postcode string) bool {
+	return db.areas[postcodesource.Area(postcode)]
+}
+
+// Iterate fires the callback for every Record in the CodePointDB, from several
+// goroutines at once.
+func (db *CodePointDB) Iterate(cb func(*postcodesource.Record) error) error {
+	return postcodesource.IterateRecords(db.data, cb)
+}
@@ -0,0 +1,61 @@
+package codepoint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/osgb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+func TestParse(t *testing.T) {
+	db := NewCodePointDB("", osgb.NewHelmert())
+
+	pc, err := db.parse([]string{"AB101AA", "10", "394251", "806376", "S92000003", "S08000020", "", "", "S12000033", "S13002842"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pc.Postcode != "AB10 1AA" {
+		t.Errorf("expected postcode AB10 1AA, got %s", pc.Postcode)
+	}
+
+	if pc.Latitude != "57.148232" || pc.Longitude != "-2.096648" {
+		t.Errorf("expected coordinates 57.148232,-2.096648, got %s,%s", pc.Latitude, pc.Longitude)
+	}
+
+	if pc.PositionalQuality != "1" {
+		t.Errorf("expected positional quality 1, got %s", pc.PositionalQuality)
+	}
+
+	pc, err = db.parse([]string{"AB1 0AA", "90", "0", "0", "S92000003", "", "", "", "S12000033", "S13002842"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pc.Latitude != postcodesource.NoLocationLatitude || pc.Longitude != postcodesource.NoLocationLongitude {
+		t.Errorf("expected no location, got %s,%s", pc.Latitude, pc.Longitude)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	db := NewCodePointDB("", osgb.NewHelmert())
+
+	err := db.load(strings.NewReader("\"AB101AA\",10,394251,806376,\"S92000003\",\"S08000020\",\"\",\"\",\"S12000033\",\"S13002842\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		"AB10 1AA": true,
+		"AB99 9ZZ": true,
+		"BT1 1AA":  false,
+		"JE2 3AB":  false,
+	}
+
+	for postcode, want := range tests {
+		if got := db.Covers(postcode); got != want {
+			t.Errorf("Covers(%s) = %t, want %t", postcode, got, want)
+		}
+	}
+}
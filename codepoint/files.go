@@ -0,0 +1,135 @@
+package codepoint
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// The per-postcode-area files in a Code-Point Open release, e.g. Data/CSV/ab.csv
+var csvRegexp = regexp.MustCompile(`(?i)^(.*/)?Data/CSV/[^/]+\.csv$`)
+
+type dataFile struct {
+	name   string
+	reader io.ReadCloser
+}
+
+type dataFiles struct {
+	files   []*dataFile
+	closers []io.Closer
+}
+
+func (d *dataFiles) Close() error {
+	var errs []error
+
+	for _, f := range d.files {
+		errs = append(errs, f.reader.Close())
+	}
+
+	for _, c := range d.closers {
+		errs = append(errs, c.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// openFiles opens every Code-Point Open CSV at path, in name order.
+func openFiles(p string) (*dataFiles, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return openDirectory(p)
+	}
+
+	if filepath.Ext(p) == ".zip" {
+		return openZip(p)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataFiles{files: []*dataFile{{name: p, reader: f}}}, nil
+}
+
+func openDirectory(p string) (*dataFiles, error) {
+	// Accept either the extracted release or its Data/CSV folder
+	names, err := filepath.Glob(filepath.Join(p, "Data", "CSV", "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		names, err = filepath.Glob(filepath.Join(p, "*.csv"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no Code-Point Open CSVs found in %s", p)
+	}
+
+	sort.Strings(names)
+
+	files := &dataFiles{}
+
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			files.Close()
+			return nil, err
+		}
+
+		files.files = append(files.files, &dataFile{name: name, reader: f})
+	}
+
+	return files, nil
+}
+
+func openZip(p string) (*dataFiles, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file %s: %w", p, err)
+	}
+
+	var entries []*zip.File
+
+	for _, zf := range zr.File {
+		if csvRegexp.MatchString(zf.Name) {
+			entries = append(entries, zf)
+		}
+	}
+
+	if len(entries) == 0 {
+		zr.Close()
+		return nil, fmt.Errorf("no Code-Point Open CSVs found in %s", p)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	files := &dataFiles{closers: []io.Closer{zr}}
+
+	for _, zf := range entries {
+		r, err := zf.Open()
+		if err != nil {
+			files.Close()
+			return nil, fmt.Errorf("failed to open %s in %s: %w", zf.Name, p, err)
+		}
+
+		files.files = append(files.files, &dataFile{name: zf.Name, reader: r})
+	}
+
+	return files, nil
+}
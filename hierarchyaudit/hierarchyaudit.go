@@ -0,0 +1,121 @@
+// Package hierarchyaudit compares the hierarchies postcodes were given by PIP
+// with the admin records matching their ONS GSS codes.
+package hierarchyaudit
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+
+	reader "github.com/whosonfirst/go-reader"
+	wof_reader "github.com/whosonfirst/go-whosonfirst-reader"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/pipclient"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+// Disagreement is a postcode whose hierarchy doesn't include the admin record
+// matching one of its ONS codes.
+type Disagreement struct {
+	Postcode string
+	// WofID is the ID of the postcode's feature
+	WofID int64
+	// Code is the GSS code from the ONS data, e.g. E10000016
+	Code string
+	// Placetype is the placetype of the admin record with the code
+	Placetype string
+	// ExpectedID is the ID of the admin record with the code
+	ExpectedID int64
+	// HierarchyIDs are the IDs of that placetype in the postcode's hierarchy
+	HierarchyIDs []int64
+	// Distance is how far in metres the postcode's point is from the edge of
+	// the expected admin record's polygon
+	Distance float64
+}
+
+// Auditor checks postcode hierarchies against the admin records in a
+// PostalRegionsDB. It's safe to use from several goroutines at once.
+type Auditor struct {
+	prDB   *postalregionsdb.PostalRegionsDB
+	reader reader.Reader
+
+	mu         sync.Mutex
+	geometries map[int64]orb.Geometry
+}
+
+// NewAuditor returns an Auditor matching codes with prDB, and reading the
+// polygons of admin records with r.
+func NewAuditor(prDB *postalregionsdb.PostalRegionsDB, r reader.Reader) *Auditor {
+	return &Auditor{prDB: prDB, reader: r, geometries: make(map[int64]orb.Geometry)}
+}
+
+// Audit returns a Disagreement for each of the postcode's district, county
+// and region codes whose admin record isn't in the hierarchy provided. Codes
+// without an admin record are ignored.
+func (a *Auditor) Audit(ctx context.Context, wofID int64, point orb.Point, hierarchies []map[string]int64, pc *postcodesource.Record) ([]*Disagreement, error) {
+	var disagreements []*Disagreement
+
+	for _, code := range []string{pc.DistrictCode, pc.CountyCode, pc.RegionCode} {
+		record := a.prDB.LookupGSS(code)
+		if record == nil {
+			continue
+		}
+
+		var ids []int64
+		for _, h := range hierarchies {
+			if id := h[record.Placetype+"_id"]; id > 0 && !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+
+		if slices.Contains(ids, record.WofID) {
+			continue
+		}
+
+		geom, err := a.geometry(ctx, record.WofID)
+		if err != nil {
+			return nil, err
+		}
+
+		disagreements = append(disagreements, &Disagreement{
+			Postcode:     pc.Postcode,
+			WofID:        wofID,
+			Code:         code,
+			Placetype:    record.Placetype,
+			ExpectedID:   record.WofID,
+			HierarchyIDs: ids,
+			Distance:     pipclient.DistanceMetres(geom, point),
+		})
+	}
+
+	return disagreements, nil
+}
+
+// geometry returns the geometry of the admin record, reading it the first
+// time it's needed.
+func (a *Auditor) geometry(ctx context.Context, id int64) (orb.Geometry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if geom, ok := a.geometries[id]; ok {
+		return geom, nil
+	}
+
+	body, err := wof_reader.LoadBytes(ctx, a.reader, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %d: %w", id, err)
+	}
+
+	f, err := geojson.UnmarshalFeature(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %d: %w", id, err)
+	}
+
+	a.geometries[id] = f.Geometry
+	return f.Geometry, nil
+}
@@ -0,0 +1,83 @@
+package hierarchyaudit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+
+	reader "github.com/whosonfirst/go-reader"
+	uri "github.com/whosonfirst/go-whosonfirst-uri"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postalregionsdb"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	path, err := uri.Id2AbsPath(root, 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"type":"Feature","properties":{"wof:id":1001,"wof:name":"Kent","wof:placetype":"county","wof:concordances":{"uk:gss":"E10000016"},"wof:hierarchy":[{"county_id":1001}]},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}`
+
+	err = os.WriteFile(path, []byte(body), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prDB := postalregionsdb.NewPostalRegionsDB(root)
+	err = prDB.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := reader.NewReader(ctx, fmt.Sprintf("fs://%s", root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auditor := NewAuditor(prDB, r)
+
+	// The region code has no admin record, so is ignored
+	pc := &postcodesource.Record{Postcode: "CT1 1AA", CountyCode: "E10000016", RegionCode: "E12000008"}
+
+	found, err := auditor.Audit(ctx, 1, orb.Point{0.5, 0.5}, []map[string]int64{{"county_id": 1001}}, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 0 {
+		t.Errorf("Audit() found %d disagreements for a matching hierarchy, want 0", len(found))
+	}
+
+	found, err = auditor.Audit(ctx, 1, orb.Point{1.003, 0.5}, []map[string]int64{{"county_id": 1002}}, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 1 {
+		t.Fatalf("Audit() found %d disagreements, want 1", len(found))
+	}
+
+	d := found[0]
+	if d.ExpectedID != 1001 || d.Placetype != "county" || len(d.HierarchyIDs) != 1 || d.HierarchyIDs[0] != 1002 {
+		t.Errorf("Audit() = %+v, want county 1001 instead of 1002", d)
+	}
+
+	if math.Round(d.Distance) != 334 {
+		t.Errorf("Audit() distance = %f, want about 334m", d.Distance)
+	}
+}
@@ -0,0 +1,357 @@
+package hull
+
+import (
+	"errors"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// How far outside the hull's bound the clipping polygon is cut down to, which
+// keeps the edges added along the cut clear of the hull
+const clipPadding = 1e-6
+
+// How far the hull's points are nudged when they touch the clipping polygon,
+// about a tenth of a millimetre in degrees
+const perturbation = 1e-9
+
+const maxPerturbations = 5
+
+// ErrDegenerate is returned by Clip if the hull keeps touching the clipping
+// polygon's edges even after its points have been nudged.
+var ErrDegenerate = errors.New("hull touches the clipping polygon's edges")
+
+// Clip returns the parts of the ring inside the outer ring of the polygon,
+// such as a hull trimmed to the coastline, as anticlockwise rings. It returns
+// nil if they don't overlap. Holes in the polygon are ignored. It uses the
+// Greiner-Hormann algorithm, nudging the ring's points slightly if they fall
+// on the polygon's edges.
+func Clip(ring orb.Ring, polygon orb.Polygon) (orb.MultiPolygon, error) {
+	if len(ring) < 4 || len(polygon) == 0 || len(polygon[0]) < 4 {
+		return nil, nil
+	}
+
+	bound := ring.Bound()
+	if !bound.Intersects(polygon[0].Bound()) {
+		return nil, nil
+	}
+
+	// Coastlines have far more points than a hull, but only the stretch near
+	// the hull matters
+	clip := clipToBound(polygon[0], bound.Pad(clipPadding))
+	if len(clip) < 4 {
+		return nil, nil
+	}
+
+	subject := ring
+
+	for i := 0; i <= maxPerturbations; i++ {
+		result, ok := intersect(subject, clip)
+		if ok {
+			return result, nil
+		}
+
+		subject = perturb(ring, float64(i+1)*perturbation)
+	}
+
+	return nil, ErrDegenerate
+}
+
+// vertex is a point in one of the doubly linked lists of the Greiner-Hormann
+// algorithm. Intersections are in both lists, linked by neighbour.
+type vertex struct {
+	point        orb.Point
+	next         *vertex
+	prev         *vertex
+	intersection bool
+	entry        bool
+	visited      bool
+	neighbour    *vertex
+	// alpha is how far along its edge an intersection is, from 0 to 1
+	alpha float64
+}
+
+// intersect returns the parts of subject inside clip, or false if they touch
+// rather than cross somewhere, which the algorithm can't handle.
+func intersect(subject orb.Ring, clip orb.Ring) (orb.MultiPolygon, bool) {
+	subjectList := newVertexList(subject)
+	clipList := newVertexList(clip)
+
+	found := false
+
+	for s := subjectList; ; {
+		sNext := nextOriginal(s)
+
+		for c := clipList; ; {
+			cNext := nextOriginal(c)
+
+			point, alphaS, alphaC, ok, degenerate := segmentIntersection(s.point, sNext.point, c.point, cNext.point)
+			if degenerate {
+				return nil, false
+			}
+
+			if ok {
+				found = true
+
+				a := &vertex{point: point, intersection: true, alpha: alphaS}
+				b := &vertex{point: point, intersection: true, alpha: alphaC}
+				a.neighbour = b
+				b.neighbour = a
+
+				insertVertex(a, s, sNext)
+				insertVertex(b, c, cNext)
+			}
+
+			c = cNext
+			if c == clipList {
+				break
+			}
+		}
+
+		s = sNext
+		if s == subjectList {
+			break
+		}
+	}
+
+	if !found {
+		if planar.RingContains(clip, subject[0]) {
+			return orb.MultiPolygon{{orient(subject.Clone())}}, true
+		}
+
+		if planar.RingContains(subject, clip[0]) {
+			return orb.MultiPolygon{{orient(clip.Clone())}}, true
+		}
+
+		return nil, true
+	}
+
+	markEntries(subjectList, clip)
+	markEntries(clipList, subject)
+
+	var result orb.MultiPolygon
+
+	for start := nextUnvisited(subjectList); start != nil; start = nextUnvisited(subjectList) {
+		ring := orb.Ring{start.point}
+
+		for current := start; !current.visited; current = current.neighbour {
+			current.visited = true
+			current.neighbour.visited = true
+
+			for {
+				if current.entry {
+					current = current.next
+				} else {
+					current = current.prev
+				}
+
+				ring = append(ring, current.point)
+
+				if current.intersection {
+					break
+				}
+			}
+		}
+
+		if len(ring) >= 4 {
+			result = append(result, orb.Polygon{orient(ring)})
+		}
+	}
+
+	return result, true
+}
+
+// newVertexList links the points of a closed ring into a circular list,
+// returning its first vertex.
+func newVertexList(ring orb.Ring) *vertex {
+	var first *vertex
+	var last *vertex
+
+	for _, p := range ring[:len(ring)-1] {
+		v := &vertex{point: p}
+
+		if first == nil {
+			first = v
+		} else {
+			last.next = v
+			v.prev = last
+		}
+
+		last = v
+	}
+
+	last.next = first
+	first.prev = last
+
+	return first
+}
+
+func nextOriginal(v *vertex) *vertex {
+	v = v.next
+	for v.intersection {
+		v = v.next
+	}
+
+	return v
+}
+
+// insertVertex puts the intersection between the original vertices start and
+// end, in order of how far along the edge it is.
+func insertVertex(v *vertex, start *vertex, end *vertex) {
+	current := start
+	for current.next != end && current.next.alpha < v.alpha {
+		current = current.next
+	}
+
+	v.next = current.next
+	v.prev = current
+	current.next.prev = v
+	current.next = v
+}
+
+// markEntries marks whether each intersection in the list enters or leaves
+// the other ring, going forwards.
+func markEntries(list *vertex, other orb.Ring) {
+	inside := planar.RingContains(other, list.point)
+
+	for v := list; ; {
+		if v.intersection {
+			v.entry = !inside
+			inside = !inside
+		}
+
+		v = v.next
+		if v == list {
+			break
+		}
+	}
+}
+
+func nextUnvisited(list *vertex) *vertex {
+	for v := list; ; {
+		if v.intersection && !v.visited {
+			return v
+		}
+
+		v = v.next
+		if v == list {
+			return nil
+		}
+	}
+}
+
+// segmentIntersection returns where the segments ab and cd cross, and how far
+// along each it is. degenerate is set if they touch at an end or overlap.
+func segmentIntersection(a orb.Point, b orb.Point, c orb.Point, d orb.Point) (point orb.Point, alphaAB float64, alphaCD float64, ok bool, degenerate bool) {
+	rx, ry := b[0]-a[0], b[1]-a[1]
+	sx, sy := d[0]-c[0], d[1]-c[1]
+
+	denominator := rx*sy - ry*sx
+
+	if denominator == 0 {
+		// Parallel segments only matter if they overlap
+		if cross(a, b, c) == 0 && (onSegment(a, b, c) || onSegment(a, b, d) || onSegment(c, d, a) || onSegment(c, d, b)) {
+			return point, 0, 0, false, true
+		}
+
+		return point, 0, 0, false, false
+	}
+
+	qx, qy := c[0]-a[0], c[1]-a[1]
+
+	alphaAB = (qx*sy - qy*sx) / denominator
+	alphaCD = (qx*ry - qy*rx) / denominator
+
+	if alphaAB < 0 || alphaAB > 1 || alphaCD < 0 || alphaCD > 1 {
+		return point, 0, 0, false, false
+	}
+
+	if alphaAB == 0 || alphaAB == 1 || alphaCD == 0 || alphaCD == 1 {
+		return point, 0, 0, false, true
+	}
+
+	point = orb.Point{a[0] + alphaAB*rx, a[1] + alphaAB*ry}
+	return point, alphaAB, alphaCD, true, false
+}
+
+// perturb nudges each point of the ring by a different small amount, keeping
+// it closed.
+func perturb(ring orb.Ring, amount float64) orb.Ring {
+	nudged := make(orb.Ring, len(ring))
+
+	for i, p := range ring[:len(ring)-1] {
+		nudged[i] = orb.Point{p[0] + amount*float64(i%3+1), p[1] + amount*float64(i%5+1)}
+	}
+
+	nudged[len(ring)-1] = nudged[0]
+
+	return nudged
+}
+
+func orient(ring orb.Ring) orb.Ring {
+	if ring.Orientation() == orb.CW {
+		ring.Reverse()
+	}
+
+	return ring
+}
+
+// clipToBound cuts the ring down to the bound with the Sutherland-Hodgman
+// algorithm. Parts of the ring which leave the bound and come back are joined
+// along its edge.
+func clipToBound(ring orb.Ring, bound orb.Bound) orb.Ring {
+	inside := []func(orb.Point) bool{
+		func(p orb.Point) bool { return p[0] >= bound.Min[0] },
+		func(p orb.Point) bool { return p[0] <= bound.Max[0] },
+		func(p orb.Point) bool { return p[1] >= bound.Min[1] },
+		func(p orb.Point) bool { return p[1] <= bound.Max[1] },
+	}
+
+	cut := []func(a orb.Point, b orb.Point) orb.Point{
+		func(a orb.Point, b orb.Point) orb.Point { return atX(a, b, bound.Min[0]) },
+		func(a orb.Point, b orb.Point) orb.Point { return atX(a, b, bound.Max[0]) },
+		func(a orb.Point, b orb.Point) orb.Point { return atY(a, b, bound.Min[1]) },
+		func(a orb.Point, b orb.Point) orb.Point { return atY(a, b, bound.Max[1]) },
+	}
+
+	points := []orb.Point(ring[:len(ring)-1])
+
+	for edge := range inside {
+		if len(points) == 0 {
+			return nil
+		}
+
+		var clipped []orb.Point
+		prev := points[len(points)-1]
+
+		for _, p := range points {
+			if inside[edge](p) {
+				if !inside[edge](prev) {
+					clipped = append(clipped, cut[edge](prev, p))
+				}
+
+				clipped = append(clipped, p)
+			} else if inside[edge](prev) {
+				clipped = append(clipped, cut[edge](prev, p))
+			}
+
+			prev = p
+		}
+
+		points = clipped
+	}
+
+	if len(points) < 3 {
+		return nil
+	}
+
+	return append(orb.Ring(points), points[0])
+}
+
+func atX(a orb.Point, b orb.Point, x float64) orb.Point {
+	return orb.Point{x, a[1] + (b[1]-a[1])*(x-a[0])/(b[0]-a[0])}
+}
+
+func atY(a orb.Point, b orb.Point, y float64) orb.Point {
+	return orb.Point{a[0] + (b[0]-a[0])*(y-a[1])/(b[1]-a[1]), y}
+}
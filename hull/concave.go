@@ -0,0 +1,207 @@
+package hull
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// Concave returns a concave hull of the points as a closed, anticlockwise
+// ring, using the k-nearest neighbours algorithm from "Concave Hull: A
+// k-nearest neighbours approach for the computation of the region occupied
+// by a set of points" by Moreira and Santos. Smaller values of k follow the
+// points more closely. k is raised until a hull containing every point is
+// found, falling back to the convex hull. It returns nil if there are fewer
+// than three points which aren't all in a line.
+func Concave(points []orb.Point, k int) orb.Ring {
+	unique := dedupe(points)
+
+	if len(unique) < 4 {
+		return Convex(unique)
+	}
+
+	if k < 3 {
+		k = 3
+	}
+
+	for ; k < len(unique); k += max(1, k/2) {
+		if ring := concaveWithK(unique, k); ring != nil {
+			return ring
+		}
+	}
+
+	return Convex(unique)
+}
+
+func concaveWithK(points []orb.Point, k int) orb.Ring {
+	dataset := make([]orb.Point, len(points))
+	copy(dataset, points)
+
+	// Start from the lowest point, which must be on the hull
+	firstIndex := 0
+	for i, p := range dataset {
+		if p[1] < dataset[firstIndex][1] || (p[1] == dataset[firstIndex][1] && p[0] < dataset[firstIndex][0]) {
+			firstIndex = i
+		}
+	}
+
+	first := dataset[firstIndex]
+	dataset = remove(dataset, firstIndex)
+
+	ring := orb.Ring{first}
+	current := first
+
+	// The direction back along the previous edge, which starts out pointing
+	// west so the first edge is the one with the shallowest climb eastwards
+	backAngle := math.Pi
+
+	for step := 2; ; step++ {
+		// Allow the hull to close once it's big enough to be a polygon
+		if step == 5 {
+			dataset = append(dataset, first)
+		}
+
+		if len(dataset) == 0 {
+			break
+		}
+
+		candidates := nearest(dataset, current, k)
+
+		// Wrap anticlockwise around the points, trying the sharpest right
+		// hand turn first
+		sort.Slice(candidates, func(i, j int) bool {
+			return turn(current, dataset[candidates[i]], backAngle) < turn(current, dataset[candidates[j]], backAngle)
+		})
+
+		next := -1
+
+		for _, c := range candidates {
+			if !intersectsRing(ring, current, dataset[c]) {
+				next = c
+				break
+			}
+		}
+
+		if next == -1 {
+			return nil
+		}
+
+		previous := current
+		current = dataset[next]
+		ring = append(ring, current)
+		dataset = remove(dataset, next)
+
+		backAngle = math.Atan2(previous[1]-current[1], previous[0]-current[0])
+
+		if current == first {
+			break
+		}
+	}
+
+	if current != first {
+		return nil
+	}
+
+	for _, p := range points {
+		if !planar.RingContains(ring, p) {
+			return nil
+		}
+	}
+
+	return ring
+}
+
+// turn returns the anticlockwise angle from the back direction to the
+// direction from a to b, between 0 and 2π.
+func turn(a orb.Point, b orb.Point, backAngle float64) float64 {
+	angle := math.Atan2(b[1]-a[1], b[0]-a[0]) - backAngle
+
+	for angle <= 0 {
+		angle += 2 * math.Pi
+	}
+
+	for angle > 2*math.Pi {
+		angle -= 2 * math.Pi
+	}
+
+	return angle
+}
+
+// nearest returns the indexes of the k points closest to p.
+func nearest(points []orb.Point, p orb.Point, k int) []int {
+	indexes := make([]int, len(points))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return planar.DistanceSquared(points[indexes[i]], p) < planar.DistanceSquared(points[indexes[j]], p)
+	})
+
+	if len(indexes) > k {
+		indexes = indexes[:k]
+	}
+
+	return indexes
+}
+
+// intersectsRing returns whether the segment ab crosses any edge of the
+// partial ring, other than at a shared end.
+func intersectsRing(ring orb.Ring, a orb.Point, b orb.Point) bool {
+	for i := 0; i < len(ring)-1; i++ {
+		c, d := ring[i], ring[i+1]
+
+		if a == c || a == d || b == c || b == d {
+			continue
+		}
+
+		if segmentsIntersect(a, b, c, d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func segmentsIntersect(a orb.Point, b orb.Point, c orb.Point, d orb.Point) bool {
+	d1 := cross(c, d, a)
+	d2 := cross(c, d, b)
+	d3 := cross(a, b, c)
+	d4 := cross(a, b, d)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+
+	return (d1 == 0 && onSegment(c, d, a)) ||
+		(d2 == 0 && onSegment(c, d, b)) ||
+		(d3 == 0 && onSegment(a, b, c)) ||
+		(d4 == 0 && onSegment(a, b, d))
+}
+
+// onSegment returns whether p, which is in line with ab, lies between them.
+func onSegment(a orb.Point, b orb.Point, p orb.Point) bool {
+	return math.Min(a[0], b[0]) <= p[0] && p[0] <= math.Max(a[0], b[0]) &&
+		math.Min(a[1], b[1]) <= p[1] && p[1] <= math.Max(a[1], b[1])
+}
+
+func dedupe(points []orb.Point) []orb.Point {
+	seen := make(map[orb.Point]bool, len(points))
+	unique := make([]orb.Point, 0, len(points))
+
+	for _, p := range points {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+
+	return unique
+}
+
+func remove(points []orb.Point, i int) []orb.Point {
+	points[i] = points[len(points)-1]
+	return points[:len(points)-1]
+}
@@ -0,0 +1,59 @@
+package hull
+
+import (
+	"sort"
+
+	"github.com/paulmach/orb"
+)
+
+// Convex returns the convex hull of the points as a closed, anticlockwise
+// ring, or nil if there are fewer than three points which aren't all in a
+// line.
+func Convex(points []orb.Point) orb.Ring {
+	sorted := make([]orb.Point, len(points))
+	copy(sorted, points)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] != sorted[j][0] {
+			return sorted[i][0] < sorted[j][0]
+		}
+
+		return sorted[i][1] < sorted[j][1]
+	})
+
+	// Andrew's monotone chain, building the lower then the upper hull
+	var ring orb.Ring
+
+	for _, p := range sorted {
+		for len(ring) >= 2 && cross(ring[len(ring)-2], ring[len(ring)-1], p) <= 0 {
+			ring = ring[:len(ring)-1]
+		}
+
+		ring = append(ring, p)
+	}
+
+	lower := len(ring) + 1
+
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+
+		for len(ring) >= lower && cross(ring[len(ring)-2], ring[len(ring)-1], p) <= 0 {
+			ring = ring[:len(ring)-1]
+		}
+
+		ring = append(ring, p)
+	}
+
+	// A triangle is four points once it's closed
+	if len(ring) < 4 {
+		return nil
+	}
+
+	return ring
+}
+
+// cross returns the z component of the cross product of ab and ac, which is
+// positive if abc turns anticlockwise.
+func cross(a orb.Point, b orb.Point, c orb.Point) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
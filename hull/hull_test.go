@@ -0,0 +1,99 @@
+package hull
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+func TestConvex(t *testing.T) {
+	ring := Convex([]orb.Point{{0, 0}, {2, 0}, {1, 1}, {2, 2}, {0, 2}, {1, 0}})
+
+	expected := orb.Ring{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}
+	if !ring.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, ring)
+	}
+
+	if ring := Convex([]orb.Point{{0, 0}, {1, 1}, {2, 2}}); ring != nil {
+		t.Errorf("expected no hull for points in a line, got %v", ring)
+	}
+}
+
+func TestConcave(t *testing.T) {
+	// A C shape, open to the east
+	var points []orb.Point
+	for x := 0.0; x <= 10; x++ {
+		for y := 0.0; y <= 10; y++ {
+			if x >= 4 && y >= 3 && y <= 7 {
+				continue
+			}
+
+			points = append(points, orb.Point{x, y})
+		}
+	}
+
+	ring := Concave(points, 3)
+	if ring == nil {
+		t.Fatal("expected a hull")
+	}
+
+	if !ring.Closed() {
+		t.Error("expected the hull to be closed")
+	}
+
+	if planar.RingContains(ring, orb.Point{8, 5}) {
+		t.Error("expected the hull to leave out the notch")
+	}
+
+	for _, p := range points {
+		if !planar.RingContains(ring, p) {
+			t.Errorf("expected the hull to contain %v", p)
+		}
+	}
+
+	if area := planar.Area(ring); area <= 0 {
+		t.Errorf("expected an anticlockwise ring, got area %f", area)
+	}
+}
+
+func TestClip(t *testing.T) {
+	// Land west of x = 2, with a bay between x = 1 and x = 3 north of y = 1
+	land := orb.Polygon{{{-10, -10}, {10, -10}, {10, 10}, {3, 10}, {3, 1}, {1, 1}, {1, 10}, {-10, 10}, {-10, -10}}}
+	coast := orb.Polygon{{{-10, -10}, {2, -10}, {2, 10}, {-10, 10}, {-10, -10}}}
+
+	tests := []struct {
+		name    string
+		ring    orb.Ring
+		polygon orb.Polygon
+		areas   []float64
+	}{
+		{"half over the sea", orb.Ring{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}, coast, []float64{8}},
+		{"around the bay", orb.Ring{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}, land, []float64{10}},
+		{"either side of the bay", orb.Ring{{0, 2}, {4, 2}, {4, 4}, {0, 4}, {0, 2}}, land, []float64{2, 2}},
+		{"on land", orb.Ring{{-4, 0}, {-2, 0}, {-2, 2}, {-4, 2}, {-4, 0}}, coast, []float64{4}},
+		{"at sea", orb.Ring{{4, 0}, {6, 0}, {6, 2}, {4, 2}, {4, 0}}, coast, nil},
+		// A point of the ring on the coast needs nudging
+		{"touching the coast", orb.Ring{{0, 0}, {2, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}, coast, []float64{8}},
+	}
+
+	for _, test := range tests {
+		clipped, err := Clip(test.ring, test.polygon)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if len(clipped) != len(test.areas) {
+			t.Errorf("%s: expected %d polygons, got %v", test.name, len(test.areas), clipped)
+			continue
+		}
+
+		for i, polygon := range clipped {
+			if area := planar.Area(polygon[0]); math.Abs(area-test.areas[i]) > 1e-6 {
+				t.Errorf("%s: expected polygon %d to have area %f, got %f", test.name, i, test.areas[i], area)
+			}
+		}
+	}
+}
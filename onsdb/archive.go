@@ -0,0 +1,185 @@
+package onsdb
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The single file containing every postcode in an ONSPD release zip, e.g.
+// Data/ONSPD_MAY_2019_UK.csv
+var ukCSVRegexp = regexp.MustCompile(`(?i)^(.*/)?Data/ONSPD_[A-Z]{3}_\d{4}_UK\.csv$`)
+
+// The per-postcode-area files in the Data/multi_csv folder of an ONSPD
+// release zip, e.g. Data/multi_csv/ONSPD_MAY_2019_UK_AB.csv
+var multiCSVRegexp = regexp.MustCompile(`(?i)^(.*/)?Data/multi_csv/[^/]+\.csv$`)
+
+// dataFile is a single CSV of ONS postcode data, possibly one of many inside
+// an archive.
+type dataFile struct {
+	name   string
+	reader io.ReadCloser
+}
+
+// dataSource holds the open CSV files for a path, plus anything else that
+// needs closing once they've all been read.
+type dataSource struct {
+	files   []*dataFile
+	closers []io.Closer
+}
+
+func (s *dataSource) Close() error {
+	var errs []error
+
+	for _, f := range s.files {
+		errs = append(errs, f.reader.Close())
+	}
+
+	for _, c := range s.closers {
+		errs = append(errs, c.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// openDataSource opens the ONS postcode data at path, which can be a plain
+// CSV, a gzipped CSV or the zip archive the ONS distributes the Postcode
+// Directory as.
+func openDataSource(p string) (*dataSource, error) {
+	lower := strings.ToLower(p)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return openZipDataSource(p)
+
+	case strings.HasSuffix(lower, ".gz"):
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open gzip file %s: %w", p, err)
+		}
+
+		file := &dataFile{name: p, reader: gz}
+		return &dataSource{files: []*dataFile{file}, closers: []io.Closer{f}}, nil
+
+	default:
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+
+		file := &dataFile{name: p, reader: f}
+		return &dataSource{files: []*dataFile{file}}, nil
+	}
+}
+
+// openZipDataSource finds the postcode data inside an ONSPD release zip.
+// The single UK-wide CSV is preferred, falling back to the multi_csv folder
+// for releases (or repackaged archives) which don't include it.
+func openZipDataSource(p string) (*dataSource, error) {
+	z, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file %s: %w", p, err)
+	}
+
+	var ukFiles []*zip.File
+	var multiFiles []*zip.File
+
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		switch {
+		case ukCSVRegexp.MatchString(f.Name):
+			ukFiles = append(ukFiles, f)
+		case multiCSVRegexp.MatchString(f.Name):
+			multiFiles = append(multiFiles, f)
+		}
+	}
+
+	if len(ukFiles) > 1 {
+		z.Close()
+		return nil, fmt.Errorf("found multiple UK-wide data files in %s", p)
+	}
+
+	selected := ukFiles
+	if len(selected) == 0 {
+		selected = multiFiles
+	}
+
+	if len(selected) == 0 {
+		z.Close()
+		return nil, fmt.Errorf("no ONSPD data files found in %s", p)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+
+	source := &dataSource{closers: []io.Closer{z}}
+
+	for _, f := range selected {
+		r, err := f.Open()
+		if err != nil {
+			source.Close()
+			return nil, fmt.Errorf("failed to open %s in %s: %w", f.Name, p, err)
+		}
+
+		name := fmt.Sprintf("%s!%s", path.Base(p), f.Name)
+		source.files = append(source.files, &dataFile{name: name, reader: r})
+	}
+
+	return source, nil
+}
+
+// checksum returns the hex encoded SHA-256 of the file at the ONSDB path. It's
+// only calculated once, as the release files run to several gigabytes.
+func (db *ONSDB) checksum() (string, error) {
+	if db.sha256 != "" {
+		return db.sha256, nil
+	}
+
+	f, err := os.Open(db.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	db.sha256 = hex.EncodeToString(h.Sum(nil))
+	return db.sha256, nil
+}
+
+// VerifyChecksum compares the SHA-256 of the file at the ONSDB path against
+// the hex encoded checksum provided.
+func (db *ONSDB) VerifyChecksum(expected string) error {
+	actual, err := db.checksum()
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", db.path, expected, actual)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package onsdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeZip builds a zip in memory with the files provided, then writes it to
+// a temporary file.
+func writeZip(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	for name, body := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.Write([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := filepath.Join(t.TempDir(), "ONSPD_MAY_2019_UK.zip")
+
+	err = os.WriteFile(p, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// readSource returns the names and contents of the files in the data source.
+func readSource(t *testing.T, source *dataSource) ([]string, []string) {
+	var names, bodies []string
+
+	for _, f := range source.files {
+		body, err := io.ReadAll(f.reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, f.name)
+		bodies = append(bodies, string(body))
+	}
+
+	return names, bodies
+}
+
+func TestOpenZipDataSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			"UK file preferred",
+			map[string]string{
+				"Data/ONSPD_MAY_2019_UK.csv":              "uk",
+				"Data/multi_csv/ONSPD_MAY_2019_UK_AB.csv": "ab",
+				"Documents/README.txt":                    "readme",
+			},
+			[]string{"uk"},
+		},
+		{
+			"multi_csv fallback",
+			map[string]string{
+				"ONSPD_MAY_2019/Data/multi_csv/ONSPD_MAY_2019_UK_B.csv":  "b",
+				"ONSPD_MAY_2019/Data/multi_csv/ONSPD_MAY_2019_UK_AB.csv": "ab",
+				"ONSPD_MAY_2019/User Guide/ONSPD_User_Guide.csv":         "guide",
+			},
+			[]string{"ab", "b"},
+		},
+	}
+
+	for _, test := range tests {
+		source, err := openZipDataSource(writeZip(t, test.files))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		names, bodies := readSource(t, source)
+		source.Close()
+
+		if !slices.Equal(bodies, test.expected) {
+			t.Errorf("%s: expected %v, got %v from %v", test.name, test.expected, bodies, names)
+		}
+	}
+}
+
+func TestOpenZipDataSourceErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"no data files": {"Documents/README.txt": "readme"},
+		"several UK files": {
+			"Data/ONSPD_MAY_2019_UK.csv": "may",
+			"Data/ONSPD_AUG_2019_UK.csv": "aug",
+		},
+	}
+
+	for name, files := range tests {
+		_, err := openZipDataSource(writeZip(t, files))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestOpenDataSourceGzip(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+
+	_, err := w.Write([]byte("pcds\nSW1A 1AA\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := filepath.Join(t.TempDir(), "onspd.csv.GZ")
+
+	err = os.WriteFile(p, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := openDataSource(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	_, bodies := readSource(t, source)
+	if len(bodies) != 1 || bodies[0] != "pcds\nSW1A 1AA\n" {
+		t.Errorf("expected the decompressed CSV, got %q", bodies)
+	}
+}
@@ -0,0 +1,149 @@
+package onsdb
+
+import (
+	"sort"
+
+	"github.com/paulmach/orb/geo"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+// PostcodeChange is a postcode which appears in both releases being compared,
+// along with which of its fields differ.
+type PostcodeChange struct {
+	Postcode string
+	Old      *postcodesource.Record
+	New      *postcodesource.Record
+	Fields   []Field
+}
+
+// PostcodeMove is a postcode whose coordinates differ between releases.
+// Distance is in metres, and is negative if either release has no usable
+// coordinates for the postcode.
+type PostcodeMove struct {
+	Postcode string
+	Old      *postcodesource.Record
+	New      *postcodesource.Record
+	Distance float64
+}
+
+// DiffReport describes the differences between two releases of the ONS
+// Postcode Directory. Every list is sorted by postcode.
+type DiffReport struct {
+	// Postcodes in the new release but not the old one
+	Added []*postcodesource.Record
+	// Postcodes in the old release but not the new one
+	Removed []*postcodesource.Record
+	// Postcodes which have gained a termination date in the new release
+	Terminated []*postcodesource.Record
+	Moved      []*PostcodeMove
+	// Changes to inception or cessation, other than the cessation of those
+	// Terminated
+	DateChanges []*PostcodeChange
+	// Changes to the country, region, county, district or positional quality
+	CodeChanges []*PostcodeChange
+}
+
+var dateFields = []Field{FieldInception, FieldCessation}
+
+var codeFields = []Field{
+	FieldCountry,
+	FieldRegion,
+	FieldCounty,
+	FieldDistrict,
+	FieldPositionalQuality,
+}
+
+// Diff compares the old and new ONSDBs. Moves shorter than minMove metres
+// are ignored, to leave out resurveys which only nudge a point.
+func Diff(old *ONSDB, new *ONSDB, minMove float64) *DiffReport {
+	report := &DiffReport{}
+
+	for postcode, newPC := range new.data {
+		oldPC, ok := old.data[postcode]
+		if !ok {
+			report.Added = append(report.Added, newPC)
+			continue
+		}
+
+		changedDates := dateFields
+
+		if oldPC.Cessation == "" && newPC.Cessation != "" {
+			report.Terminated = append(report.Terminated, newPC)
+
+			// The new cessation is reported as the termination, but a changed
+			// inception still needs reporting
+			changedDates = []Field{FieldInception}
+		}
+
+		if fields := changedFields(oldPC, newPC, changedDates); len(fields) > 0 {
+			report.DateChanges = append(report.DateChanges, &PostcodeChange{Postcode: postcode, Old: oldPC, New: newPC, Fields: fields})
+		}
+
+		if fields := changedFields(oldPC, newPC, codeFields); len(fields) > 0 {
+			report.CodeChanges = append(report.CodeChanges, &PostcodeChange{Postcode: postcode, Old: oldPC, New: newPC, Fields: fields})
+		}
+
+		if oldPC.Latitude == newPC.Latitude && oldPC.Longitude == newPC.Longitude {
+			continue
+		}
+
+		distance := -1.0
+
+		oldPoint, oldOK := oldPC.Point()
+		newPoint, newOK := newPC.Point()
+
+		if oldOK && newOK {
+			distance = geo.DistanceHaversine(oldPoint, newPoint)
+
+			if distance < minMove {
+				continue
+			}
+		}
+
+		report.Moved = append(report.Moved, &PostcodeMove{Postcode: postcode, Old: oldPC, New: newPC, Distance: distance})
+	}
+
+	for postcode, oldPC := range old.data {
+		if _, ok := new.data[postcode]; !ok {
+			report.Removed = append(report.Removed, oldPC)
+		}
+	}
+
+	sortPostcodes(report.Added)
+	sortPostcodes(report.Removed)
+	sortPostcodes(report.Terminated)
+
+	sort.Slice(report.Moved, func(i, j int) bool {
+		return report.Moved[i].Postcode < report.Moved[j].Postcode
+	})
+
+	sortChanges(report.DateChanges)
+	sortChanges(report.CodeChanges)
+
+	return report
+}
+
+func changedFields(old *postcodesource.Record, new *postcodesource.Record, fields []Field) []Field {
+	var changed []Field
+
+	for _, field := range fields {
+		if old.Value(string(field)) != new.Value(string(field)) {
+			changed = append(changed, field)
+		}
+	}
+
+	return changed
+}
+
+func sortPostcodes(pcs []*postcodesource.Record) {
+	sort.Slice(pcs, func(i, j int) bool {
+		return pcs[i].Postcode < pcs[j].Postcode
+	})
+}
+
+func sortChanges(changes []*PostcodeChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Postcode < changes[j].Postcode
+	})
+}
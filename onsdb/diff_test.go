@@ -0,0 +1,152 @@
+package onsdb
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+func validRecord() *postcodesource.Record {
+	return &postcodesource.Record{
+		Postcode:    "SW1A 1AA",
+		Latitude:    "51.501009",
+		Longitude:   "-0.141588",
+		Inception:   "198001",
+		CountryCode: "E92000001",
+	}
+}
+
+func testDB(records ...*postcodesource.Record) *ONSDB {
+	db := NewONSDB("")
+
+	for _, pc := range records {
+		db.data[pc.Postcode] = pc
+	}
+
+	return db
+}
+
+func record(postcode string, modify func(pc *postcodesource.Record)) *postcodesource.Record {
+	pc := validRecord()
+	pc.Postcode = postcode
+
+	if modify != nil {
+		modify(pc)
+	}
+
+	return pc
+}
+
+func postcodes(pcs []*postcodesource.Record) []string {
+	var names []string
+	for _, pc := range pcs {
+		names = append(names, pc.Postcode)
+	}
+
+	return names
+}
+
+func TestDiff(t *testing.T) {
+	old := testDB(
+		record("AB1 0AA", nil),
+		record("AB1 0AB", nil),
+		record("AB1 0AD", nil),
+		record("AB1 0AE", nil),
+		record("AB1 0AF", nil),
+		record("AB1 0AG", nil),
+		record("AB1 0AH", func(pc *postcodesource.Record) { pc.Cessation = "201801" }),
+		record("AB1 0AJ", nil),
+		record("AB1 0AL", nil),
+		record("AB1 0AP", nil),
+	)
+
+	new := testDB(
+		record("AB1 0AA", nil),
+		record("AB1 0AD", func(pc *postcodesource.Record) { pc.Cessation = "201901" }),
+		// About 1.1km north
+		record("AB1 0AE", func(pc *postcodesource.Record) { pc.Latitude = "51.511009" }),
+		// Less than a metre east
+		record("AB1 0AF", func(pc *postcodesource.Record) { pc.Longitude = "-0.141580" }),
+		record("AB1 0AG", func(pc *postcodesource.Record) {
+			pc.Latitude = postcodesource.NoLocationLatitude
+			pc.Longitude = postcodesource.NoLocationLongitude
+		}),
+		record("AB1 0AH", func(pc *postcodesource.Record) { pc.Cessation = "201901" }),
+		record("AB1 0AJ", func(pc *postcodesource.Record) {
+			pc.DistrictCode = "E09000033"
+			pc.PositionalQuality = "1"
+		}),
+		record("AB1 0AL", func(pc *postcodesource.Record) { pc.Inception = "198101" }),
+		record("AB1 0AP", func(pc *postcodesource.Record) {
+			pc.Inception = "198101"
+			pc.Cessation = "201901"
+		}),
+		record("AB1 0AN", nil),
+		record("AB1 0AM", nil),
+	)
+
+	report := Diff(old, new, 10)
+
+	if added := postcodes(report.Added); !slices.Equal(added, []string{"AB1 0AM", "AB1 0AN"}) {
+		t.Errorf("expected AB1 0AM and AB1 0AN added, got %v", added)
+	}
+
+	if removed := postcodes(report.Removed); !slices.Equal(removed, []string{"AB1 0AB"}) {
+		t.Errorf("expected AB1 0AB removed, got %v", removed)
+	}
+
+	if terminated := postcodes(report.Terminated); !slices.Equal(terminated, []string{"AB1 0AD", "AB1 0AP"}) {
+		t.Errorf("expected AB1 0AD and AB1 0AP terminated, got %v", terminated)
+	}
+
+	if len(report.Moved) != 2 {
+		t.Fatalf("expected 2 moves, got %d", len(report.Moved))
+	}
+
+	if move := report.Moved[0]; move.Postcode != "AB1 0AE" || move.Distance < 1100 || move.Distance > 1120 {
+		t.Errorf("expected AB1 0AE to move about 1.1km, got %s %.1fm", move.Postcode, move.Distance)
+	}
+
+	if move := report.Moved[1]; move.Postcode != "AB1 0AG" || move.Distance != -1 {
+		t.Errorf("expected AB1 0AG to move an unknown distance, got %s %.1fm", move.Postcode, move.Distance)
+	}
+
+	if len(report.DateChanges) != 3 {
+		t.Fatalf("expected 3 date changes, got %d", len(report.DateChanges))
+	}
+
+	if change := report.DateChanges[0]; change.Postcode != "AB1 0AH" || !slices.Equal(change.Fields, []Field{FieldCessation}) {
+		t.Errorf("expected AB1 0AH's cessation to change, got %s %v", change.Postcode, change.Fields)
+	}
+
+	if change := report.DateChanges[1]; change.Postcode != "AB1 0AL" || !slices.Equal(change.Fields, []Field{FieldInception}) {
+		t.Errorf("expected AB1 0AL's inception to change, got %s %v", change.Postcode, change.Fields)
+	}
+
+	// Terminated, but its inception change is still reported
+	if change := report.DateChanges[2]; change.Postcode != "AB1 0AP" || !slices.Equal(change.Fields, []Field{FieldInception}) {
+		t.Errorf("expected AB1 0AP's inception to change, got %s %v", change.Postcode, change.Fields)
+	}
+
+	if len(report.CodeChanges) != 1 {
+		t.Fatalf("expected 1 code change, got %d", len(report.CodeChanges))
+	}
+
+	if change := report.CodeChanges[0]; change.Postcode != "AB1 0AJ" || !slices.Equal(change.Fields, []Field{FieldDistrict, FieldPositionalQuality}) {
+		t.Errorf("expected AB1 0AJ's district and positional quality to change, got %s %v", change.Postcode, change.Fields)
+	}
+}
+
+func TestDiffMinMove(t *testing.T) {
+	old := testDB(record("AB1 0AA", nil))
+	new := testDB(record("AB1 0AA", func(pc *postcodesource.Record) { pc.Latitude = "51.511009" }))
+
+	if moved := Diff(old, new, 2000).Moved; len(moved) != 0 {
+		t.Errorf("expected a 1.1km move to be ignored with a 2km minimum, got %d moves", len(moved))
+	}
+
+	if moved := Diff(old, new, 0).Moved; len(moved) != 1 {
+		t.Errorf("expected the move without a minimum, got %d moves", len(moved))
+	}
+}
@@ -1,106 +1,208 @@
 package onsdb
 
 import (
-	"context"
+	stdcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"log"
-	"os"
-	"runtime"
+	"slices"
+	"sort"
+	"strings"
 
-	"golang.org/x/sync/errgroup"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
 
 	"github.com/smartystreets/scanners/csv"
 )
 
-// PostcodeData represents an individual postcode with its associated data
-type PostcodeData struct {
-	Postcode          string `csv:"pcds"`
-	Latitude          string `csv:"lat"`
-	Longitude         string `csv:"long"`
-	Inception         string `csv:"dointr"`
-	Cessation         string `csv:"doterm"`
-	CountryCode       string `csv:"ctry"`
-	RegionCode        string `csv:"rgn"`
-	CountyCode        string `csv:"oscty"`
-	DistrictCode      string `csv:"oslaua"`
-	PositionalQuality string `csv:"osgrdind"`
-}
-
 // ONSDB is a wrapper around an SQLite database containing the ONS Poscode Directory
 type ONSDB struct {
-	data map[string]*PostcodeData
-	path string
+	data         map[string]*postcodesource.Record
+	path         string
+	schema       *Schema
+	extraColumns []string
+	sha256       string
+	policy       InvalidRowPolicy
+	report       *ValidationReport
 }
 
 // NewONSDB creates a new ONSDB at the path specified
 func NewONSDB(path string) *ONSDB {
-	data := make(map[string]*PostcodeData)
-	return &ONSDB{path: path, data: data}
+	data := make(map[string]*postcodesource.Record)
+	return &ONSDB{path: path, data: data, policy: InvalidRowsFail, report: &ValidationReport{}}
+}
+
+// SetInvalidRowPolicy sets what Build does with rows that fail validation.
+// The default is InvalidRowsFail.
+func (db *ONSDB) SetInvalidRowPolicy(policy InvalidRowPolicy) {
+	db.policy = policy
+}
+
+// Report returns the issues found with the ONS data while building the ONSDB.
+func (db *ONSDB) Report() *ValidationReport {
+	return db.report
+}
+
+// SetSchema forces the ONSDB to read the CSV using the schema provided,
+// rather than detecting it from the header row.
+func (db *ONSDB) SetSchema(schema *Schema) {
+	db.schema = schema
+}
+
+// SetExtraColumns makes the ONSDB keep the values of the columns provided, as
+// well as the ones it always reads, in Record.Extra. Column names are
+// matched case-insensitively against the CSV header.
+func (db *ONSDB) SetExtraColumns(columns []string) {
+	db.extraColumns = nil
+
+	for _, column := range columns {
+		column = strings.ToLower(column)
+
+		if slices.Contains(requiredFields, Field(column)) || slices.Contains(db.extraColumns, column) {
+			continue
+		}
+
+		db.extraColumns = append(db.extraColumns, column)
+	}
+
+	sort.Strings(db.extraColumns)
 }
 
+// Build loads the ONS postcode data from the ONSDB path. The path can be a
+// CSV, a gzipped CSV or an ONSPD release zip. Every row is validated, and
+// invalid rows are either skipped or make Build fail, depending on the
+// InvalidRowPolicy.
 func (db *ONSDB) Build() error {
-	f, err := os.Open(db.path)
+	err := db.build()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	scanner, err := csv.NewStructScanner(f)
+	return db.checkReport()
+}
+
+func (db *ONSDB) build() error {
+	source, err := openDataSource(db.path)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	defer source.Close()
 
-	for scanner.Scan() {
-		var pcData PostcodeData
-		if err := scanner.Populate(&pcData); err != nil {
+	for _, f := range source.files {
+		log.Printf("Loading ONS data from %s", f.name)
+
+		err := db.load(f.name, f.reader)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkReport applies the InvalidRowPolicy to the validation report.
+func (db *ONSDB) checkReport() error {
+	invalid := db.report.InvalidRows()
+	if invalid == 0 {
+		return nil
+	}
+
+	if db.policy == InvalidRowsSkip {
+		log.Printf("Skipped %d invalid rows in the ONS data", invalid)
+		return nil
+	}
+
+	return fmt.Errorf("found %d invalid rows in the ONS data", invalid)
+}
+
+func (db *ONSDB) load(name string, r io.Reader) error {
+	scanner := csv.NewScanner(r, csv.ContinueOnError(true))
+
+	if !scanner.Scan() {
+		if err := scanner.Error(); err != nil {
 			return err
 		}
 
-		db.data[pcData.Postcode] = &pcData
+		return errors.New("missing header row")
+	}
+
+	mapping, err := detectSchema(scanner.Record(), db.schema, db.extraColumns)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Using ONSPD schema %s", mapping.schema.Name)
+
+	seen := func(postcode string) bool {
+		_, ok := db.data[postcode]
+		return ok
+	}
+
+	// The header is row 1
+	row := 1
+
+	for scanner.Scan() {
+		row++
+
+		if err := scanner.Error(); err != nil {
+			var parseErr *stdcsv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+
+			db.report.Issues = append(db.report.Issues, &RowIssue{File: name, Row: row, Reason: parseErr.Err.Error()})
+			continue
+		}
+
+		pcData := mapping.populate(scanner.Record())
+
+		// Schemas may use the fixed width pcd or pcd2 columns for postcodes
+		if canonical, err := postcodevalidator.Normalise(pcData.Postcode); err == nil {
+			pcData.Postcode = canonical
+		}
+
+		issues := validateRow(pcData, seen)
+		if len(issues) > 0 {
+			for _, issue := range issues {
+				issue.File = name
+				issue.Row = row
+			}
+
+			db.report.Issues = append(db.report.Issues, issues...)
+			continue
+		}
+
+		db.data[pcData.Postcode] = pcData
 	}
 
 	return scanner.Error()
 }
 
-// GetPostcodeData returns the PostcodeData for the postcode provided
-func (db *ONSDB) GetPostcodeData(postcode string) (*PostcodeData, error) {
+// Lookup returns the Record for the postcode provided
+func (db *ONSDB) Lookup(postcode string) (*postcodesource.Record, error) {
 	pcData := db.data[postcode]
 	return pcData, nil
 }
 
-func (db *ONSDB) Iterate(cb func(*PostcodeData) error) error {
-	workerCount := runtime.NumCPU() * 2
-	workChan := make(chan *PostcodeData, workerCount*2)
-
-	g, ctx := errgroup.WithContext(context.Background())
-
-	for i := 0; i < workerCount; i++ {
-		g.Go(func() error {
-			for {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-
-				case pc, more := <-workChan:
-					if !more {
-						return nil
-					}
-
-					err := cb(pc)
-					if err != nil {
-						return err
-					}
-				}
-			}
-		})
+// Metadata describes the ONS Postcode Directory loaded into the ONSDB.
+func (db *ONSDB) Metadata() *postcodesource.Metadata {
+	return &postcodesource.Metadata{
+		Name:    "ONSPD",
+		Path:    db.path,
+		Licence: "OGL-UK-3.0, with Northern Ireland postcodes under the ONS Northern Ireland end user licence",
+		Count:   len(db.data),
 	}
+}
 
-	go func() {
-		for _, pc := range db.data {
-			workChan <- pc
-		}
-
-		close(workChan)
-	}()
+// Covers returns true, as the ONS Postcode Directory covers every UK postcode
+// area, along with the Channel Islands and the Isle of Man.
+func (db *ONSDB) Covers(postcode string) bool {
+	return true
+}
 
-	return g.Wait()
+// Iterate fires the callback for every Record in the ONSDB, from several
+// goroutines at once.
+func (db *ONSDB) Iterate(cb func(*postcodesource.Record) error) error {
+	return postcodesource.IterateRecords(db.data, cb)
 }
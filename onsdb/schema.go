@@ -0,0 +1,212 @@
+package onsdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+// Field is one of the ONSPD columns the sync relies on. Fields are named
+// after their column in current releases of the Postcode Directory.
+type Field string
+
+const (
+	FieldPostcode          Field = postcodesource.KeyPostcode
+	FieldLatitude          Field = postcodesource.KeyLatitude
+	FieldLongitude         Field = postcodesource.KeyLongitude
+	FieldInception         Field = postcodesource.KeyInception
+	FieldCessation         Field = postcodesource.KeyCessation
+	FieldCountry           Field = postcodesource.KeyCountry
+	FieldRegion            Field = postcodesource.KeyRegion
+	FieldCounty            Field = postcodesource.KeyCounty
+	FieldDistrict          Field = postcodesource.KeyDistrict
+	FieldPositionalQuality Field = postcodesource.KeyPositionalQuality
+)
+
+// requiredFields lists every Field which must be found in the CSV header,
+// because a missing column would otherwise overwrite good data with blanks.
+var requiredFields = []Field{
+	FieldPostcode,
+	FieldLatitude,
+	FieldLongitude,
+	FieldInception,
+	FieldCessation,
+	FieldCountry,
+	FieldRegion,
+	FieldCounty,
+	FieldDistrict,
+	FieldPositionalQuality,
+}
+
+// Schema maps the Fields the sync uses to the column names of a particular
+// set of ONSPD releases.
+type Schema struct {
+	Name    string
+	Columns map[Field]string
+}
+
+// Column returns the name of the CSV column holding the Field in this schema.
+func (s *Schema) Column(field Field) string {
+	column, ok := s.Columns[field]
+	if !ok {
+		return string(field)
+	}
+
+	return column
+}
+
+// Schemas is the registry of known ONSPD column layouts, newest first. When
+// detecting the schema of a CSV the first one whose columns are all present
+// in the header is used.
+var Schemas = []*Schema{
+	{
+		// From 2024 the local authority district column lost its `os` prefix
+		Name: "2024",
+		Columns: map[Field]string{
+			FieldDistrict: "lad",
+		},
+	},
+	{
+		Name:    "2011",
+		Columns: map[Field]string{},
+	},
+	{
+		// Releases before 2011 didn't use the `os` prefix for county or
+		// district
+		Name: "legacy",
+		Columns: map[Field]string{
+			FieldCounty:   "cty",
+			FieldDistrict: "laua",
+		},
+	},
+}
+
+// GetSchema returns the registered schema with the name provided.
+func GetSchema(name string) (*Schema, error) {
+	for _, s := range Schemas {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+
+	names := make([]string, len(Schemas))
+	for i, s := range Schemas {
+		names[i] = s.Name
+	}
+
+	return nil, fmt.Errorf("unknown ONSPD schema %s, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// schemaMapping is a Schema bound to the column positions of one CSV header.
+type schemaMapping struct {
+	schema  *Schema
+	indexes map[Field]int
+	extras  map[string]int
+}
+
+func (m *schemaMapping) value(record []string, field Field) string {
+	i, ok := m.indexes[field]
+	if !ok || i >= len(record) {
+		return ""
+	}
+
+	return record[i]
+}
+
+func (m *schemaMapping) populate(record []string) *postcodesource.Record {
+	pcData := &postcodesource.Record{
+		Postcode:          m.value(record, FieldPostcode),
+		Latitude:          m.value(record, FieldLatitude),
+		Longitude:         m.value(record, FieldLongitude),
+		Inception:         m.value(record, FieldInception),
+		Cessation:         m.value(record, FieldCessation),
+		CountryCode:       m.value(record, FieldCountry),
+		RegionCode:        m.value(record, FieldRegion),
+		CountyCode:        m.value(record, FieldCounty),
+		DistrictCode:      m.value(record, FieldDistrict),
+		PositionalQuality: m.value(record, FieldPositionalQuality),
+	}
+
+	if len(m.extras) > 0 {
+		pcData.Extra = make(map[string]string, len(m.extras))
+
+		for column, i := range m.extras {
+			if i < len(record) {
+				pcData.Extra[column] = record[i]
+			}
+		}
+	}
+
+	return pcData
+}
+
+// mapSchema binds the schema and any extra columns to the header, returning
+// the columns it can't find.
+func mapSchema(schema *Schema, header map[string]int, extraColumns []string) (*schemaMapping, []string) {
+	mapping := &schemaMapping{schema: schema, indexes: make(map[Field]int), extras: make(map[string]int)}
+	var missing []string
+
+	for _, field := range requiredFields {
+		column := schema.Column(field)
+
+		i, ok := header[column]
+		if !ok {
+			missing = append(missing, column)
+			continue
+		}
+
+		mapping.indexes[field] = i
+	}
+
+	for _, column := range extraColumns {
+		i, ok := header[column]
+		if !ok {
+			missing = append(missing, column)
+			continue
+		}
+
+		mapping.extras[column] = i
+	}
+
+	return mapping, missing
+}
+
+// detectSchema picks the schema matching the CSV header. If schema is non-nil
+// it's used rather than trying each registered one in turn. Any extra
+// columns must also be present in the header.
+func detectSchema(header []string, schema *Schema, extraColumns []string) (*schemaMapping, error) {
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		// Strip the byte order mark some releases start with
+		column = strings.TrimPrefix(column, "\ufeff")
+		column = strings.ToLower(strings.TrimSpace(column))
+
+		columns[column] = i
+	}
+
+	candidates := Schemas
+	if schema != nil {
+		candidates = []*Schema{schema}
+	}
+
+	var closest *Schema
+	var closestMissing []string
+
+	for _, s := range candidates {
+		mapping, missing := mapSchema(s, columns, extraColumns)
+		if len(missing) == 0 {
+			return mapping, nil
+		}
+
+		if closest == nil || len(missing) < len(closestMissing) {
+			closest = s
+			closestMissing = missing
+		}
+	}
+
+	sort.Strings(closestMissing)
+
+	return nil, fmt.Errorf("CSV header doesn't match any known ONSPD schema, closest is %s which is missing required columns: %s", closest.Name, strings.Join(closestMissing, ", "))
+}
@@ -0,0 +1,39 @@
+package onsdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectSchema(t *testing.T) {
+	tests := map[string]string{
+		"pcd,pcds,dointr,doterm,oscty,oslaua,osgrdind,ctry,rgn,lat,long":   "2011",
+		"pcd,pcds,dointr,doterm,oscty,lad,osgrdind,ctry,rgn,lat,long":      "2024",
+		"PCD,PCDS,DOINTR,DOTERM,CTY,LAUA,OSGRDIND,CTRY,RGN,LAT,LONG":       "legacy",
+		"\ufeffpcds,dointr,doterm,oscty,oslaua,osgrdind,ctry,rgn,lat,long": "2011",
+	}
+
+	for header, expected := range tests {
+		mapping, err := detectSchema(strings.Split(header, ","), nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to detect schema for '%s', %v", header, err)
+		}
+
+		if mapping.schema.Name != expected {
+			t.Fatalf("Expected schema %s for '%s', got %s", expected, header, mapping.schema.Name)
+		}
+	}
+}
+
+func TestDetectSchemaMissingColumns(t *testing.T) {
+	header := strings.Split("pcds,dointr,doterm,oslaua,ctry,rgn,lat,long", ",")
+
+	_, err := detectSchema(header, nil, nil)
+	if err == nil {
+		t.Fatal("Expected an error for a header missing columns")
+	}
+
+	if !strings.Contains(err.Error(), "oscty, osgrdind") {
+		t.Fatalf("Expected error to name the missing columns, got %v", err)
+	}
+}
@@ -0,0 +1,417 @@
+package onsdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+// Snapshots start with a magic string and format version, so that stale or
+// foreign files are rejected rather than misread.
+const snapshotMagic = "ONSDBSNP"
+const snapshotVersion uint32 = 4
+
+const maxSnapshotString = 1 << 16
+const maxSnapshotColumns = 1 << 10
+
+// Coordinates are tagged with how they're stored
+const (
+	coordinateString byte = iota
+	coordinateFloat
+)
+
+// The decimal places of the coordinates in the ONS data
+const coordinatePrecision = 6
+
+var errSnapshotMismatch = errors.New("snapshot doesn't match the ONS data")
+
+// snapshotKey identifies the ONS data a snapshot was built from, the schema
+// forced on it, if any, and the extra columns it holds.
+type snapshotKey struct {
+	size         int64
+	sha256       string
+	schema       string
+	extraColumns []string
+}
+
+func (db *ONSDB) snapshotKey() (*snapshotKey, error) {
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := db.checksum()
+	if err != nil {
+		return nil, err
+	}
+
+	// Empty when the schema is detected from the header
+	schema := ""
+	if db.schema != nil {
+		schema = db.schema.Name
+	}
+
+	return &snapshotKey{size: fi.Size(), sha256: sum, schema: schema, extraColumns: db.extraColumns}, nil
+}
+
+// BuildWithSnapshot loads the ONS data from the snapshot at snapshotPath if
+// it was built from the same file as the ONSDB path. Otherwise it builds the
+// database from the ONS data as normal, then writes a new snapshot for the
+// next run. The validation report is saved in the snapshot, so invalid rows
+// are handled the same way whichever the data is loaded from.
+func (db *ONSDB) BuildWithSnapshot(snapshotPath string) error {
+	key, err := db.snapshotKey()
+	if err != nil {
+		return err
+	}
+
+	err = db.readSnapshot(snapshotPath, key)
+	if err == nil {
+		log.Printf("Loaded %d postcodes from snapshot %s", len(db.data), snapshotPath)
+		return db.checkReport()
+	}
+
+	if errors.Is(err, errSnapshotMismatch) {
+		log.Printf("Snapshot %s is for a different ONS file, rebuilding", snapshotPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Unable to read snapshot %s, rebuilding: %s", snapshotPath, err)
+	}
+
+	// Don't keep anything from a partially read snapshot
+	db.data = make(map[string]*postcodesource.Record)
+	db.report = &ValidationReport{}
+
+	err = db.build()
+	if err != nil {
+		return err
+	}
+
+	err = db.writeSnapshot(snapshotPath, key)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot %s: %w", snapshotPath, err)
+	}
+
+	log.Printf("Wrote snapshot %s", snapshotPath)
+	return db.checkReport()
+}
+
+func (db *ONSDB) readSnapshot(path string, key *snapshotKey) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := &snapshotReader{r: bufio.NewReaderSize(f, 1<<20)}
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r.r, magic); err != nil {
+		return err
+	}
+
+	if string(magic) != snapshotMagic {
+		return fmt.Errorf("%s is not an ONSDB snapshot", path)
+	}
+
+	if version := uint32(r.uvarint()); version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	size := int64(r.uvarint())
+	sum := r.string()
+	schema := r.string()
+
+	extraCount := r.uvarint()
+	if extraCount > maxSnapshotColumns {
+		return fmt.Errorf("invalid column count %d in snapshot", extraCount)
+	}
+
+	extraColumns := make([]string, extraCount)
+	for i := range extraColumns {
+		extraColumns[i] = r.string()
+	}
+
+	count := r.uvarint()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	if size != key.size || sum != key.sha256 || schema != key.schema || !slices.Equal(extraColumns, key.extraColumns) {
+		return errSnapshotMismatch
+	}
+
+	for i := uint64(0); i < count; i++ {
+		pcData := &postcodesource.Record{
+			Postcode:          r.string(),
+			Latitude:          r.coordinate(),
+			Longitude:         r.coordinate(),
+			Inception:         r.code(),
+			Cessation:         r.code(),
+			CountryCode:       r.code(),
+			RegionCode:        r.code(),
+			CountyCode:        r.code(),
+			DistrictCode:      r.code(),
+			PositionalQuality: r.code(),
+		}
+
+		if len(extraColumns) > 0 {
+			pcData.Extra = make(map[string]string, len(extraColumns))
+
+			for _, column := range extraColumns {
+				pcData.Extra[column] = r.code()
+			}
+		}
+
+		if r.err != nil {
+			return r.err
+		}
+
+		db.data[pcData.Postcode] = pcData
+	}
+
+	issueCount := r.uvarint()
+	for i := uint64(0); i < issueCount; i++ {
+		issue := &RowIssue{
+			File:     r.string(),
+			Row:      int(r.uvarint()),
+			Postcode: r.string(),
+			Field:    Field(r.string()),
+			Value:    r.string(),
+			Reason:   r.string(),
+		}
+
+		if r.err != nil {
+			return r.err
+		}
+
+		db.report.Issues = append(db.report.Issues, issue)
+	}
+
+	return r.err
+}
+
+// writeSnapshot writes to a temporary file first, so an interrupted run
+// can't leave a truncated snapshot behind.
+func (db *ONSDB) writeSnapshot(path string, key *snapshotKey) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := &snapshotWriter{w: bufio.NewWriterSize(tmp, 1<<20), codes: make(map[string]uint64)}
+
+	w.raw([]byte(snapshotMagic))
+	w.uvarint(uint64(snapshotVersion))
+	w.uvarint(uint64(key.size))
+	w.string(key.sha256)
+	w.string(key.schema)
+
+	w.uvarint(uint64(len(key.extraColumns)))
+	for _, column := range key.extraColumns {
+		w.string(column)
+	}
+
+	w.uvarint(uint64(len(db.data)))
+
+	for _, pcData := range db.data {
+		w.string(pcData.Postcode)
+		w.coordinate(pcData.Latitude)
+		w.coordinate(pcData.Longitude)
+		w.code(pcData.Inception)
+		w.code(pcData.Cessation)
+		w.code(pcData.CountryCode)
+		w.code(pcData.RegionCode)
+		w.code(pcData.CountyCode)
+		w.code(pcData.DistrictCode)
+		w.code(pcData.PositionalQuality)
+
+		for _, column := range key.extraColumns {
+			w.code(pcData.Extra[column])
+		}
+	}
+
+	w.uvarint(uint64(len(db.report.Issues)))
+
+	for _, issue := range db.report.Issues {
+		w.string(issue.File)
+		w.uvarint(uint64(issue.Row))
+		w.string(issue.Postcode)
+		w.string(string(issue.Field))
+		w.string(issue.Value)
+		w.string(issue.Reason)
+	}
+
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+
+	if w.err != nil {
+		tmp.Close()
+		return w.err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// snapshotWriter writes length prefixed values, holding on to the first
+// error so the callers don't need to check every write.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+	// codes numbers the codes written so far, from 1
+	codes map[string]uint64
+}
+
+func (w *snapshotWriter) raw(b []byte) {
+	if w.err != nil {
+		return
+	}
+
+	_, w.err = w.w.Write(b)
+}
+
+func (w *snapshotWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.buf[:], v)
+	w.raw(w.buf[:n])
+}
+
+func (w *snapshotWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+
+	if w.err != nil {
+		return
+	}
+
+	_, w.err = w.w.WriteString(s)
+}
+
+// coordinate writes a coordinate as a fixed width float64 if it has the six
+// decimal places of the ONS data, and as a string otherwise, so that it reads
+// back unchanged.
+func (w *snapshotWriter) coordinate(s string) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || strconv.FormatFloat(v, 'f', coordinatePrecision, 64) != s {
+		w.raw([]byte{coordinateString})
+		w.string(s)
+		return
+	}
+
+	var b [9]byte
+	b[0] = coordinateFloat
+	binary.LittleEndian.PutUint64(b[1:], math.Float64bits(v))
+	w.raw(b[:])
+}
+
+// code writes a value repeated across many postcodes, such as a date or GSS
+// code, as the number of its first appearance. The first time it appears, 0
+// is written followed by the value itself.
+func (w *snapshotWriter) code(s string) {
+	if n, ok := w.codes[s]; ok {
+		w.uvarint(n)
+		return
+	}
+
+	w.codes[s] = uint64(len(w.codes) + 1)
+	w.uvarint(0)
+	w.string(s)
+}
+
+// snapshotReader is the counterpart to snapshotWriter.
+type snapshotReader struct {
+	r     *bufio.Reader
+	err   error
+	codes []string
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	var v uint64
+	v, r.err = binary.ReadUvarint(r.r)
+	return v
+}
+
+func (r *snapshotReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+
+	// No value in the ONS data comes close to this, so the file is corrupt
+	if n > maxSnapshotString {
+		r.err = fmt.Errorf("invalid string length %d in snapshot", n)
+		return ""
+	}
+
+	b := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, b)
+	return string(b)
+}
+
+func (r *snapshotReader) coordinate() string {
+	if r.err != nil {
+		return ""
+	}
+
+	var b [9]byte
+	if _, r.err = io.ReadFull(r.r, b[:1]); r.err != nil {
+		return ""
+	}
+
+	switch b[0] {
+	case coordinateString:
+		return r.string()
+	case coordinateFloat:
+		if _, r.err = io.ReadFull(r.r, b[1:]); r.err != nil {
+			return ""
+		}
+
+		v := math.Float64frombits(binary.LittleEndian.Uint64(b[1:]))
+		return strconv.FormatFloat(v, 'f', coordinatePrecision, 64)
+	}
+
+	r.err = fmt.Errorf("invalid coordinate tag %d in snapshot", b[0])
+	return ""
+}
+
+// code reads a value written by snapshotWriter.code, sharing one string
+// between the postcodes it appears in.
+func (r *snapshotReader) code() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+
+	if n == 0 {
+		s := r.string()
+		r.codes = append(r.codes, s)
+		return s
+	}
+
+	if n > uint64(len(r.codes)) {
+		r.err = fmt.Errorf("invalid code reference %d in snapshot", n)
+		return ""
+	}
+
+	return r.codes[n-1]
+}
@@ -0,0 +1,120 @@
+package onsdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestCSV(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "onspd.csv")
+
+	csv := "pcds,dointr,doterm,oscty,oslaua,osgrdind,ctry,rgn,lat,long,ru11ind\n" +
+		"SW1A 1AA,198001,,E99999999,E09000033,1,E92000001,E12000007,51.501009,-0.141588,A1\n" +
+		"EC1A 1BB,198001,201901,E99999999,E09000001,1,E92000001,E12000007,51.520124,-0.097953,A1\n" +
+		"SW1A 1A,198001,,E99999999,E09000033,1,E92000001,E12000007,51.501009,-0.141588,A1\n" +
+		"N1 9GU,200001,,E99999999,E09000019,1,E92000001,E12000007,51.53,-0.12,A1\n"
+
+	err := os.WriteFile(path, []byte(csv), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := writeTestCSV(t)
+	snapshotPath := filepath.Join(t.TempDir(), "ons.snapshot")
+
+	built := NewONSDB(path)
+	built.SetInvalidRowPolicy(InvalidRowsSkip)
+	built.SetExtraColumns([]string{"ru11ind"})
+
+	err := built.BuildWithSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewONSDB(path)
+	loaded.SetExtraColumns([]string{"ru11ind"})
+
+	key, err := loaded.snapshotKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = loaded.readSnapshot(snapshotPath, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.data) != len(built.data) {
+		t.Fatalf("expected %d postcodes from the snapshot, got %d", len(built.data), len(loaded.data))
+	}
+
+	for postcode, expected := range built.data {
+		pc := loaded.data[postcode]
+		if pc == nil || pc.Latitude != expected.Latitude || pc.Longitude != expected.Longitude || pc.Cessation != expected.Cessation || pc.DistrictCode != expected.DistrictCode || pc.Extra["ru11ind"] != expected.Extra["ru11ind"] {
+			t.Errorf("expected %+v from the snapshot, got %+v", expected, pc)
+		}
+	}
+
+	if len(loaded.report.Issues) != 1 || loaded.report.Issues[0].Row != 4 {
+		t.Errorf("expected the invalid row from the snapshot, got %+v", loaded.report.Issues)
+	}
+}
+
+func TestSnapshotKeyMismatch(t *testing.T) {
+	path := writeTestCSV(t)
+	snapshotPath := filepath.Join(t.TempDir(), "ons.snapshot")
+
+	db := NewONSDB(path)
+	db.SetInvalidRowPolicy(InvalidRowsSkip)
+
+	err := db.BuildWithSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := GetSchema("2011")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forced := NewONSDB(path)
+	forced.SetSchema(schema)
+
+	extra := NewONSDB(path)
+	extra.SetExtraColumns([]string{"ru11ind"})
+
+	for name, db := range map[string]*ONSDB{"forced schema": forced, "extra columns": extra} {
+		key, err := db.snapshotKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.readSnapshot(snapshotPath, key)
+		if !errors.Is(err, errSnapshotMismatch) {
+			t.Errorf("%s: expected a snapshot mismatch, got %v", name, err)
+		}
+	}
+
+	err = os.WriteFile(path, []byte("pcds\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := NewONSDB(path)
+
+	key, err := changed.snapshotKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = changed.readSnapshot(snapshotPath, key)
+	if !errors.Is(err, errSnapshotMismatch) {
+		t.Errorf("changed file: expected a snapshot mismatch, got %v", err)
+	}
+}
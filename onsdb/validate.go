@@ -0,0 +1,157 @@
+package onsdb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+)
+
+// InvalidRowPolicy decides what Build does with rows that fail validation.
+type InvalidRowPolicy string
+
+const (
+	// InvalidRowsFail makes Build return an error if any row is invalid
+	InvalidRowsFail InvalidRowPolicy = "fail"
+	// InvalidRowsSkip leaves invalid rows out of the ONSDB
+	InvalidRowsSkip InvalidRowPolicy = "skip"
+)
+
+// ParseInvalidRowPolicy returns the InvalidRowPolicy with the name provided.
+func ParseInvalidRowPolicy(s string) (InvalidRowPolicy, error) {
+	switch p := InvalidRowPolicy(s); p {
+	case InvalidRowsFail, InvalidRowsSkip:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("unknown invalid row policy %s, expected %s or %s", s, InvalidRowsFail, InvalidRowsSkip)
+}
+
+// The bounds of the UK, Channel Islands and Isle of Man, with some margin
+const (
+	minLatitude  = 49.0
+	maxLatitude  = 61.0
+	minLongitude = -9.0
+	maxLongitude = 2.0
+)
+
+// countryCodes lists the GSS codes of every country in the ONS data
+var countryCodes = map[string]string{
+	"E92000001": "England",
+	"W92000004": "Wales",
+	"S92000003": "Scotland",
+	"N92000002": "Northern Ireland",
+	"L93000001": "Channel Islands",
+	"M83000003": "Isle of Man",
+}
+
+// RowIssue is a problem found with a row of the ONS data. Row counts from 1
+// for the header, within the file named.
+type RowIssue struct {
+	File     string
+	Row      int
+	Postcode string
+	Field    Field
+	Value    string
+	Reason   string
+}
+
+// ValidationReport lists every invalid row found while building an ONSDB.
+type ValidationReport struct {
+	Issues []*RowIssue
+}
+
+// InvalidRows returns the number of rows with at least one issue.
+func (r *ValidationReport) InvalidRows() int {
+	count := 0
+
+	for i, issue := range r.Issues {
+		if i == 0 || issue.File != r.Issues[i-1].File || issue.Row != r.Issues[i-1].Row {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Write writes the report as CSV.
+func (r *ValidationReport) Write(out io.Writer) error {
+	w := csv.NewWriter(out)
+
+	err := w.Write([]string{"file", "row", "postcode", "field", "value", "reason"})
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range r.Issues {
+		w.Write([]string{issue.File, strconv.Itoa(issue.Row), issue.Postcode, string(issue.Field), issue.Value, issue.Reason})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// validateRow checks a row, returning any issues found with it. seen is used
+// to detect postcodes which have already been loaded.
+func validateRow(pc *postcodesource.Record, seen func(string) bool) []*RowIssue {
+	var issues []*RowIssue
+
+	add := func(field Field, reason string) {
+		issues = append(issues, &RowIssue{Postcode: pc.Postcode, Field: field, Value: pc.Value(string(field)), Reason: reason})
+	}
+
+	if !postcodevalidator.Validate(pc.Postcode) {
+		add(FieldPostcode, "invalid postcode")
+	} else if seen(pc.Postcode) {
+		add(FieldPostcode, "duplicate postcode")
+	}
+
+	if pc.Latitude != postcodesource.NoLocationLatitude || pc.Longitude != postcodesource.NoLocationLongitude {
+		if reason := checkCoordinate(pc.Latitude, minLatitude, maxLatitude); reason != "" {
+			add(FieldLatitude, reason)
+		}
+
+		if reason := checkCoordinate(pc.Longitude, minLongitude, maxLongitude); reason != "" {
+			add(FieldLongitude, reason)
+		}
+	}
+
+	if pc.Inception == "" {
+		add(FieldInception, "missing date")
+	} else if _, err := parseONSDate(pc.Inception); err != nil {
+		add(FieldInception, "invalid date, expected YYYYMM")
+	}
+
+	if pc.Cessation != "" {
+		if _, err := parseONSDate(pc.Cessation); err != nil {
+			add(FieldCessation, "invalid date, expected YYYYMM")
+		}
+	}
+
+	if _, ok := countryCodes[pc.CountryCode]; !ok {
+		add(FieldCountry, "unknown country code")
+	}
+
+	return issues
+}
+
+func checkCoordinate(s string, min float64, max float64) string {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "invalid coordinate"
+	}
+
+	if v < min || v > max {
+		return "coordinate outside the UK"
+	}
+
+	return ""
+}
+
+func parseONSDate(s string) (time.Time, error) {
+	return time.Parse("200601", s)
+}
@@ -0,0 +1,154 @@
+package onsdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+func TestValidateRow(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(pc *postcodesource.Record)
+		field  Field
+		reason string
+	}{
+		{"invalid postcode", func(pc *postcodesource.Record) { pc.Postcode = "SW1A 1A" }, FieldPostcode, "invalid postcode"},
+		{"duplicate postcode", func(pc *postcodesource.Record) { pc.Postcode = "EC1A 1BB" }, FieldPostcode, "duplicate postcode"},
+		{"latitude not a number", func(pc *postcodesource.Record) { pc.Latitude = "north" }, FieldLatitude, "invalid coordinate"},
+		{"latitude outside the UK", func(pc *postcodesource.Record) { pc.Latitude = "48.5" }, FieldLatitude, "coordinate outside the UK"},
+		{"longitude outside the UK", func(pc *postcodesource.Record) { pc.Longitude = "3.1" }, FieldLongitude, "coordinate outside the UK"},
+		{"missing inception", func(pc *postcodesource.Record) { pc.Inception = "" }, FieldInception, "missing date"},
+		{"invalid inception", func(pc *postcodesource.Record) { pc.Inception = "1980-01" }, FieldInception, "invalid date, expected YYYYMM"},
+		{"invalid cessation", func(pc *postcodesource.Record) { pc.Cessation = "201913" }, FieldCessation, "invalid date, expected YYYYMM"},
+		{"unknown country", func(pc *postcodesource.Record) { pc.CountryCode = "X99999999" }, FieldCountry, "unknown country code"},
+	}
+
+	seen := func(postcode string) bool {
+		return postcode == "EC1A 1BB"
+	}
+
+	for _, test := range tests {
+		pc := validRecord()
+		test.modify(pc)
+
+		issues := validateRow(pc, seen)
+		if len(issues) != 1 {
+			t.Errorf("%s: expected 1 issue, got %d", test.name, len(issues))
+			continue
+		}
+
+		if issues[0].Field != test.field || issues[0].Reason != test.reason {
+			t.Errorf("%s: expected %s %q, got %s %q", test.name, test.field, test.reason, issues[0].Field, issues[0].Reason)
+		}
+	}
+}
+
+func TestValidateRowValid(t *testing.T) {
+	noLocation := validRecord()
+	noLocation.Latitude = postcodesource.NoLocationLatitude
+	noLocation.Longitude = postcodesource.NoLocationLongitude
+
+	terminated := validRecord()
+	terminated.Cessation = "201901"
+
+	for _, pc := range []*postcodesource.Record{validRecord(), noLocation, terminated} {
+		issues := validateRow(pc, func(string) bool { return false })
+		if len(issues) != 0 {
+			t.Errorf("expected no issues for %+v, got %q", pc, issues[0].Reason)
+		}
+	}
+}
+
+func TestInvalidRows(t *testing.T) {
+	pc := validRecord()
+	pc.Postcode = "NOT A POSTCODE"
+	pc.Inception = ""
+	pc.CountryCode = ""
+
+	issues := validateRow(pc, func(string) bool { return false })
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues, got %d", len(issues))
+	}
+
+	for _, issue := range issues {
+		issue.File = "a.csv"
+		issue.Row = 2
+	}
+
+	report := &ValidationReport{Issues: issues}
+	report.Issues = append(report.Issues,
+		&RowIssue{File: "a.csv", Row: 3, Reason: "extra \" in field"},
+		&RowIssue{File: "b.csv", Row: 3, Reason: "missing date"},
+	)
+
+	if invalid := report.InvalidRows(); invalid != 3 {
+		t.Errorf("expected 3 invalid rows, got %d", invalid)
+	}
+}
+
+func TestCheckReport(t *testing.T) {
+	db := NewONSDB("")
+
+	err := db.checkReport()
+	if err != nil {
+		t.Errorf("expected no error without issues, got %v", err)
+	}
+
+	db.report.Issues = []*RowIssue{{File: "a.csv", Row: 2, Reason: "missing date"}}
+
+	err = db.checkReport()
+	if err == nil {
+		t.Error("expected an error for invalid rows by default")
+	}
+
+	db.SetInvalidRowPolicy(InvalidRowsSkip)
+
+	err = db.checkReport()
+	if err != nil {
+		t.Errorf("expected invalid rows to be skipped, got %v", err)
+	}
+
+	db.SetInvalidRowPolicy(InvalidRowsFail)
+
+	err = db.checkReport()
+	if err == nil {
+		t.Error("expected an error for invalid rows with the fail policy")
+	}
+}
+
+func TestBuildInvalidRowPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "onspd.csv")
+
+	csv := "pcds,dointr,doterm,oscty,oslaua,osgrdind,ctry,rgn,lat,long\n" +
+		"SW1A 1AA,198001,,E99999999,E09000033,1,E92000001,E12000007,51.501009,-0.141588\n" +
+		"SW1A 1A,198001,,E99999999,E09000033,1,E92000001,E12000007,51.501009,-0.141588\n"
+
+	err := os.WriteFile(path, []byte(csv), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewONSDB(path).Build()
+	if err == nil {
+		t.Error("expected Build to fail on the invalid row")
+	}
+
+	db := NewONSDB(path)
+	db.SetInvalidRowPolicy(InvalidRowsSkip)
+
+	err = db.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := db.Metadata().Count; count != 1 {
+		t.Errorf("expected the invalid row to be skipped, got %d postcodes", count)
+	}
+
+	if issues := db.Report().Issues; len(issues) != 1 || issues[0].Row != 3 {
+		t.Errorf("expected an issue for row 3, got %+v", issues)
+	}
+}
@@ -0,0 +1,86 @@
+package osgb
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// The OSGB36 to WGS84 Helmert transformation. Translations are in metres,
+// scale in parts per million and rotations in arcseconds.
+const (
+	helmertTX = 446.448
+	helmertTY = -125.157
+	helmertTZ = 542.060
+	helmertS  = -20.4894
+	helmertRX = 0.1502
+	helmertRY = 0.2470
+	helmertRZ = 0.8421
+)
+
+// Helmert converts National Grid coordinates to WGS84 with a seven parameter
+// Helmert transformation. It's accurate to around 5 metres, which is plenty
+// for a postcode centroid, and doesn't need any extra data.
+type Helmert struct{}
+
+// NewHelmert returns a Helmert Transformer.
+func NewHelmert() *Helmert {
+	return &Helmert{}
+}
+
+func (h *Helmert) ToWGS84(easting float64, northing float64) (orb.Point, error) {
+	lat, lon := gridToLatLon(easting, northing, airy1830)
+
+	x, y, z := toCartesian(lat, lon, airy1830)
+
+	s := helmertS * 1e-6
+	rx := helmertRX / 3600 * math.Pi / 180
+	ry := helmertRY / 3600 * math.Pi / 180
+	rz := helmertRZ / 3600 * math.Pi / 180
+
+	x2 := helmertTX + (1+s)*x - rz*y + ry*z
+	y2 := helmertTY + rz*x + (1+s)*y - rx*z
+	z2 := helmertTZ - ry*x + rx*y + (1+s)*z
+
+	lat, lon = fromCartesian(x2, y2, z2, grs80)
+
+	return toDegrees(lat, lon), nil
+}
+
+// toCartesian converts a latitude and longitude in radians, at zero height on
+// the ellipsoid, to cartesian coordinates.
+func toCartesian(lat float64, lon float64, el ellipsoid) (float64, float64, float64) {
+	e2 := el.e2()
+	sinLat := math.Sin(lat)
+	nu := el.a / math.Sqrt(1-e2*sinLat*sinLat)
+
+	x := nu * math.Cos(lat) * math.Cos(lon)
+	y := nu * math.Cos(lat) * math.Sin(lon)
+	z := (1 - e2) * nu * sinLat
+
+	return x, y, z
+}
+
+// fromCartesian converts cartesian coordinates to a latitude and longitude in
+// radians on the ellipsoid.
+func fromCartesian(x float64, y float64, z float64, el ellipsoid) (float64, float64) {
+	e2 := el.e2()
+	p := math.Sqrt(x*x + y*y)
+
+	lat := math.Atan2(z, p*(1-e2))
+
+	for i := 0; i < 10; i++ {
+		sinLat := math.Sin(lat)
+		nu := el.a / math.Sqrt(1-e2*sinLat*sinLat)
+
+		next := math.Atan2(z+e2*nu*sinLat, p)
+		if math.Abs(next-lat) < 1e-12 {
+			lat = next
+			break
+		}
+
+		lat = next
+	}
+
+	return lat, math.Atan2(y, x)
+}
@@ -0,0 +1,101 @@
+package osgb
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// Transformer converts British National Grid eastings and northings to WGS84
+// coordinates.
+type Transformer interface {
+	ToWGS84(easting float64, northing float64) (orb.Point, error)
+}
+
+type ellipsoid struct {
+	a float64
+	b float64
+}
+
+func (e ellipsoid) e2() float64 {
+	return 1 - (e.b*e.b)/(e.a*e.a)
+}
+
+var airy1830 = ellipsoid{a: 6377563.396, b: 6356256.909}
+var grs80 = ellipsoid{a: 6378137.000, b: 6356752.3141}
+
+// The National Grid transverse Mercator projection
+const (
+	f0   = 0.9996012717
+	lat0 = 49.0 * math.Pi / 180
+	lon0 = -2.0 * math.Pi / 180
+	e0   = 400000.0
+	n0   = -100000.0
+)
+
+// gridToLatLon inverts the National Grid projection on the ellipsoid given,
+// returning the latitude and longitude in radians. The formulae are from
+// "A guide to coordinate systems in Great Britain" by Ordnance Survey.
+func gridToLatLon(easting float64, northing float64, el ellipsoid) (float64, float64) {
+	a, b := el.a, el.b
+	e2 := el.e2()
+	n := (a - b) / (a + b)
+
+	lat := lat0
+	m := 0.0
+
+	for {
+		lat = (northing-n0-m)/(a*f0) + lat
+		m = meridionalArc(lat, b, n)
+
+		if math.Abs(northing-n0-m) < 0.00001 {
+			break
+		}
+	}
+
+	sinLat := math.Sin(lat)
+	tanLat := math.Tan(lat)
+	secLat := 1 / math.Cos(lat)
+
+	nu := a * f0 / math.Sqrt(1-e2*sinLat*sinLat)
+	rho := a * f0 * (1 - e2) / math.Pow(1-e2*sinLat*sinLat, 1.5)
+	eta2 := nu/rho - 1
+
+	tan2 := tanLat * tanLat
+	tan4 := tan2 * tan2
+	tan6 := tan4 * tan2
+
+	vii := tanLat / (2 * rho * nu)
+	viii := tanLat / (24 * rho * math.Pow(nu, 3)) * (5 + 3*tan2 + eta2 - 9*tan2*eta2)
+	ix := tanLat / (720 * rho * math.Pow(nu, 5)) * (61 + 90*tan2 + 45*tan4)
+	x := secLat / nu
+	xi := secLat / (6 * math.Pow(nu, 3)) * (nu/rho + 2*tan2)
+	xii := secLat / (120 * math.Pow(nu, 5)) * (5 + 28*tan2 + 24*tan4)
+	xiia := secLat / (5040 * math.Pow(nu, 7)) * (61 + 662*tan2 + 1320*tan4 + 720*tan6)
+
+	de := easting - e0
+
+	lat = lat - vii*math.Pow(de, 2) + viii*math.Pow(de, 4) - ix*math.Pow(de, 6)
+	lon := lon0 + x*de - xi*math.Pow(de, 3) + xii*math.Pow(de, 5) - xiia*math.Pow(de, 7)
+
+	return lat, lon
+}
+
+func meridionalArc(lat float64, b float64, n float64) float64 {
+	n2 := n * n
+	n3 := n2 * n
+
+	dLat := lat - lat0
+	sLat := lat + lat0
+
+	ma := (1 + n + 5.0/4*n2 + 5.0/4*n3) * dLat
+	mb := (3*n + 3*n2 + 21.0/8*n3) * math.Sin(dLat) * math.Cos(sLat)
+	mc := (15.0/8*n2 + 15.0/8*n3) * math.Sin(2*dLat) * math.Cos(2*sLat)
+	md := 35.0 / 24 * n3 * math.Sin(3*dLat) * math.Cos(3*sLat)
+
+	return b * f0 * (ma - mb + mc - md)
+}
+
+func toDegrees(lat float64, lon float64) orb.Point {
+	return orb.Point{lon * 180 / math.Pi, lat * 180 / math.Pi}
+}
@@ -0,0 +1,65 @@
+package osgb
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGridToLatLon(t *testing.T) {
+	// The worked example from "A guide to coordinate systems in Great Britain"
+	lat, lon := gridToLatLon(651409.903, 313177.270, airy1830)
+	pt := toDegrees(lat, lon)
+
+	expectedLat := 52 + 39.0/60 + 27.2531/3600
+	expectedLon := 1 + 43.0/60 + 4.5177/3600
+
+	if math.Abs(pt.Lat()-expectedLat) > 1e-7 || math.Abs(pt.Lon()-expectedLon) > 1e-7 {
+		t.Fatalf("Expected %f,%f, got %f,%f", expectedLat, expectedLon, pt.Lat(), pt.Lon())
+	}
+}
+
+func TestHelmert(t *testing.T) {
+	// The same worked example, which is 52°39'28.723"N 1°42'57.787"E in WGS84
+	pt, err := NewHelmert().ToWGS84(651409.903, 313177.270)
+	if err != nil {
+		t.Fatalf("Failed to transform coordinates, %v", err)
+	}
+
+	expectedLat := 52 + 39.0/60 + 28.723/3600
+	expectedLon := 1 + 42.0/60 + 57.787/3600
+
+	if math.Abs(pt.Lat()-expectedLat) > 1e-5 || math.Abs(pt.Lon()-expectedLon) > 1e-5 {
+		t.Fatalf("Expected %f,%f, got %f,%f", expectedLat, expectedLon, pt.Lat(), pt.Lon())
+	}
+}
+
+func TestOSTN15Fallback(t *testing.T) {
+	// An empty grid has no shifts anywhere, as if every point were offshore
+	grid := newOSTN15()
+
+	tests := []struct {
+		easting  float64
+		northing float64
+	}{
+		{651409.903, 313177.270},
+		// Outside the grid altogether
+		{-1000, 313177.270},
+		{651409.903, 1300000},
+	}
+
+	for _, test := range tests {
+		pt, err := grid.ToWGS84(test.easting, test.northing)
+		if err != nil {
+			t.Fatalf("Failed to transform %f,%f, %v", test.easting, test.northing, err)
+		}
+
+		expected, err := NewHelmert().ToWGS84(test.easting, test.northing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pt != expected {
+			t.Errorf("Expected the Helmert result %v for %f,%f, got %v", expected, test.easting, test.northing, pt)
+		}
+	}
+}
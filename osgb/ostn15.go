@@ -0,0 +1,159 @@
+package osgb
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/paulmach/orb"
+	"github.com/smartystreets/scanners/csv"
+)
+
+// The OSTN15 grid covers the National Grid at 1km resolution
+const (
+	ostn15Columns = 701
+	ostn15Rows    = 1251
+	ostn15Spacing = 1000.0
+)
+
+var errOutsideOSTN15 = errors.New("coordinates are outside the OSTN15 grid")
+
+type ostn15Shift struct {
+	east  float64
+	north float64
+}
+
+// OSTN15 converts National Grid coordinates to WGS84 using the Ordnance Survey's
+// OSTN15 transformation, which is accurate to around 10cm. It treats ETRS89
+// as equivalent to WGS84, which is good to within a metre or so. Points the
+// grid doesn't cover are converted with the Helmert transformation instead.
+type OSTN15 struct {
+	shifts []ostn15Shift
+	// fallback converts points outside the grid, or offshore where it has
+	// no shifts
+	fallback Transformer
+}
+
+// LoadOSTN15 reads the OSTN15 grid from the OSTN15_OSGM15_DataFile.txt CSV
+// published by Ordnance Survey.
+func LoadOSTN15(path string) (*OSTN15, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner, err := csv.NewColumnScanner(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OSTN15 header from %s: %w", path, err)
+	}
+
+	grid := newOSTN15()
+
+	for scanner.Scan() {
+		id, err := strconv.Atoi(scanner.Column("Point_ID"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OSTN15 point ID: %w", err)
+		}
+
+		if id < 1 || id > len(grid.shifts) {
+			return nil, fmt.Errorf("OSTN15 point ID %d out of range", id)
+		}
+
+		east, err := strconv.ParseFloat(scanner.Column("ETRS89_OSGB36_EShift"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OSTN15 easting shift for point %d: %w", id, err)
+		}
+
+		north, err := strconv.ParseFloat(scanner.Column("ETRS89_OSGB36_NShift"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OSTN15 northing shift for point %d: %w", id, err)
+		}
+
+		grid.shifts[id-1] = ostn15Shift{east: east, north: north}
+	}
+
+	err = scanner.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return grid, nil
+}
+
+func newOSTN15() *OSTN15 {
+	return &OSTN15{shifts: make([]ostn15Shift, ostn15Columns*ostn15Rows), fallback: NewHelmert()}
+}
+
+// shift interpolates the OSTN15 shifts at the ETRS89 easting and northing.
+func (g *OSTN15) shift(easting float64, northing float64) (ostn15Shift, error) {
+	column := int(math.Floor(easting / ostn15Spacing))
+	row := int(math.Floor(northing / ostn15Spacing))
+
+	if column < 0 || row < 0 || column+1 >= ostn15Columns || row+1 >= ostn15Rows {
+		return ostn15Shift{}, errOutsideOSTN15
+	}
+
+	corners := [4]ostn15Shift{
+		g.shifts[column+row*ostn15Columns],
+		g.shifts[column+1+row*ostn15Columns],
+		g.shifts[column+1+(row+1)*ostn15Columns],
+		g.shifts[column+(row+1)*ostn15Columns],
+	}
+
+	// Points offshore have no shift in the grid
+	for _, c := range corners {
+		if c.east == 0 && c.north == 0 {
+			return ostn15Shift{}, errOutsideOSTN15
+		}
+	}
+
+	t := (easting - float64(column)*ostn15Spacing) / ostn15Spacing
+	u := (northing - float64(row)*ostn15Spacing) / ostn15Spacing
+
+	interpolate := func(v0, v1, v2, v3 float64) float64 {
+		return (1-t)*(1-u)*v0 + t*(1-u)*v1 + t*u*v2 + (1-t)*u*v3
+	}
+
+	return ostn15Shift{
+		east:  interpolate(corners[0].east, corners[1].east, corners[2].east, corners[3].east),
+		north: interpolate(corners[0].north, corners[1].north, corners[2].north, corners[3].north),
+	}, nil
+}
+
+func (g *OSTN15) ToWGS84(easting float64, northing float64) (orb.Point, error) {
+	// OSTN15 gives the shift from ETRS89 to OSGB36 at ETRS89 coordinates, so
+	// it's applied in reverse until the ETRS89 coordinates settle.
+	etrsEasting := easting
+	etrsNorthing := northing
+
+	for i := 0; i < 20; i++ {
+		s, err := g.shift(etrsEasting, etrsNorthing)
+		if errors.Is(err, errOutsideOSTN15) {
+			log.Printf("%.0f,%.0f is outside the OSTN15 grid, using the Helmert transformation", easting, northing)
+			return g.fallback.ToWGS84(easting, northing)
+		}
+
+		if err != nil {
+			return orb.Point{}, err
+		}
+
+		nextEasting := easting - s.east
+		nextNorthing := northing - s.north
+
+		done := math.Abs(nextEasting-etrsEasting) < 0.0001 && math.Abs(nextNorthing-etrsNorthing) < 0.0001
+
+		etrsEasting = nextEasting
+		etrsNorthing = nextNorthing
+
+		if done {
+			break
+		}
+	}
+
+	lat, lon := gridToLatLon(etrsEasting, etrsNorthing, grs80)
+	return toDegrees(lat, lon), nil
+}
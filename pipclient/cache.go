@@ -0,0 +1,219 @@
+package pipclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/paulmach/orb"
+	"github.com/whosonfirst/go-whosonfirst-spatial/filter"
+
+	export "github.com/whosonfirst/go-whosonfirst-export/v2"
+)
+
+// CacheStats counts how the PIP cache has been used.
+type CacheStats struct {
+	// Hits is the number of lookups answered from the cache
+	Hits uint64
+	// Misses is the number of lookups which needed a full PIP
+	Misses uint64
+	// BoundaryHits is the number of hits which strict mode found to be in
+	// different polygons to the rest of their cell, so did a full PIP for
+	BoundaryHits uint64
+	// BoundaryCells is the number of cells which weren't cached as they
+	// cross the edge of a polygon
+	BoundaryCells uint64
+}
+
+// cellKey is a cell of the cache's grid, for a list of placetypes.
+type cellKey struct {
+	placetypes string
+	x          int64
+	y          int64
+}
+
+type cacheEntry struct {
+	// ids are the sorted IDs of the polygons containing the whole cell
+	ids      []string
+	toAssign map[string]interface{}
+}
+
+type cache struct {
+	cellSize float64
+	strict   bool
+
+	mu    sync.RWMutex
+	cells map[cellKey]*cacheEntry
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	boundaryHits  atomic.Uint64
+	boundaryCells atomic.Uint64
+}
+
+// EnableCache makes the client reuse hierarchies for points in the same cell
+// of a grid cellSize degrees across, as long as the cell is inside the same
+// polygons at each of its corners. A polygon can still cross a cell without
+// covering any of its corners, so in strict mode every hit is rechecked
+// against the polygons containing the real point, which is slower than a
+// plain hit but still skips resolving the hierarchy.
+func (client *PIPClient) EnableCache(cellSize float64, strict bool) error {
+	if cellSize <= 0 {
+		return fmt.Errorf("invalid PIP cache cell size %f", cellSize)
+	}
+
+	client.cache = &cache{
+		cellSize: cellSize,
+		strict:   strict,
+		cells:    make(map[cellKey]*cacheEntry),
+	}
+
+	return nil
+}
+
+// CacheStats returns the stats of the PIP cache, which are all zero if it
+// isn't enabled.
+func (client *PIPClient) CacheStats() CacheStats {
+	if client.cache == nil {
+		return CacheStats{}
+	}
+
+	return CacheStats{
+		Hits:          client.cache.hits.Load(),
+		Misses:        client.cache.misses.Load(),
+		BoundaryHits:  client.cache.boundaryHits.Load(),
+		BoundaryCells: client.cache.boundaryCells.Load(),
+	}
+}
+
+func (client *PIPClient) updateHierarchyCached(ctx context.Context, bytes []byte, placetypes []string) ([]byte, error) {
+	c := client.cache
+
+	centroid, err := client.resolver.PointInPolygonCentroid(ctx, bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.key(*centroid, placetypes)
+
+	c.mu.RLock()
+	entry := c.cells[key]
+	c.mu.RUnlock()
+
+	if entry != nil {
+		hit := true
+
+		if c.strict {
+			ids, err := client.containing(ctx, *centroid, placetypes)
+			if err != nil {
+				return nil, err
+			}
+
+			hit = slices.Equal(ids, entry.ids)
+		}
+
+		if hit {
+			c.hits.Add(1)
+
+			_, newBytes, err := export.AssignPropertiesIfChanged(ctx, bytes, entry.toAssign)
+			return newBytes, err
+		}
+
+		c.boundaryHits.Add(1)
+	}
+
+	c.misses.Add(1)
+
+	toAssign, newBytes, err := client.resolve(ctx, bytes, placetypes)
+	if err != nil || toAssign == nil || entry != nil {
+		return newBytes, err
+	}
+
+	ids, interior, err := client.cellInterior(ctx, key, *centroid, placetypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Points outside every polygon may have been parented by the nearest
+	// one, which might not be the nearest for the rest of the cell
+	if len(ids) == 0 {
+		return newBytes, nil
+	}
+
+	if !interior {
+		c.boundaryCells.Add(1)
+		return newBytes, nil
+	}
+
+	c.mu.Lock()
+	c.cells[key] = &cacheEntry{ids: ids, toAssign: toAssign}
+	c.mu.Unlock()
+
+	return newBytes, nil
+}
+
+func (c *cache) key(point orb.Point, placetypes []string) cellKey {
+	return cellKey{
+		placetypes: strings.Join(placetypes, ","),
+		x:          int64(math.Floor(point.X() / c.cellSize)),
+		y:          int64(math.Floor(point.Y() / c.cellSize)),
+	}
+}
+
+// cellInterior returns the polygons containing the point, and whether each
+// corner of its cell is in the same ones.
+func (client *PIPClient) cellInterior(ctx context.Context, key cellKey, point orb.Point, placetypes []string) ([]string, bool, error) {
+	ids, err := client.containing(ctx, point, placetypes)
+	if err != nil {
+		return nil, false, err
+	}
+
+	size := client.cache.cellSize
+
+	for _, dx := range []int64{0, 1} {
+		for _, dy := range []int64{0, 1} {
+			corner := orb.Point{float64(key.x+dx) * size, float64(key.y+dy) * size}
+
+			cornerIDs, err := client.containing(ctx, corner, placetypes)
+			if err != nil {
+				return nil, false, err
+			}
+
+			if !slices.Equal(ids, cornerIDs) {
+				return ids, false, nil
+			}
+		}
+	}
+
+	return ids, true, nil
+}
+
+// containing returns the sorted IDs of the polygons of the placetypes which
+// contain the point.
+func (client *PIPClient) containing(ctx context.Context, point orb.Point, placetypes []string) ([]string, error) {
+	sprFilter, err := filter.NewSPRFilterFromInputs(sprInputs())
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := client.database.PointInPolygon(ctx, &point, sprFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, r := range rsp.Results() {
+		if slices.Contains(placetypes, r.Placetype()) {
+			ids = append(ids, r.Id())
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
@@ -0,0 +1,83 @@
+package pipclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	uri "github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+func writeSquare(t *testing.T, root string, id int64, placetype string, size float64, hierarchy string, extra string) {
+	t.Helper()
+
+	path, err := uri.Id2AbsPath(root, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:id":%d,"wof:name":"%s","wof:placetype":"%s","wof:parent_id":-1,"wof:country":"GB","wof:repo":"whosonfirst-data-admin-gb","mz:is_current":1,%s"wof:hierarchy":[%s]},"bbox":[0,0,%[6]g,%[6]g],"geometry":{"type":"Polygon","coordinates":[[[0,0],[%[6]g,0],[%[6]g,%[6]g],[0,%[6]g],[0,0]]]}}`, id, placetype, placetype, extra, hierarchy, size)
+
+	err = os.WriteFile(path, []byte(body), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	writeSquare(t, root, 1001, "county", 2, `{"county_id":1001}`, "")
+	writeSquare(t, root, 1002, "locality", 1, `{"county_id":1001,"locality_id":1002}`, "")
+
+	client, err := NewPIPClient(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.EnableCache(0.01, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lon    float64
+		lat    float64
+		parent int64
+	}{
+		{0.501, 0.501, 1002},
+		{0.502, 0.502, 1002},
+		// Crosses the edge of the locality, so isn't cached
+		{0.999, 0.5, 1002},
+		{1.505, 0.5, 1001},
+	}
+
+	for _, test := range tests {
+		body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:name":"AB1 1AA","wof:placetype":"postalcode"},"geometry":{"type":"Point","coordinates":[%g,%g]}}`, test.lon, test.lat)
+
+		updated, err := client.UpdateHierarchy(ctx, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parent := gjson.GetBytes(updated, "properties.wof:parent_id").Int()
+		if parent != test.parent {
+			t.Errorf("parent of %g,%g = %d, want %d", test.lon, test.lat, parent, test.parent)
+		}
+	}
+
+	stats := client.CacheStats()
+	want := CacheStats{Hits: 1, Misses: 3, BoundaryCells: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
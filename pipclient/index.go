@@ -0,0 +1,206 @@
+package pipclient
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+	"github.com/sfomuseum/go-edtf"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-whosonfirst-feature/geometry"
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+	"github.com/whosonfirst/go-whosonfirst-spatial/database"
+
+	hierarchy "github.com/whosonfirst/go-whosonfirst-spatial/hierarchy"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/admindata"
+)
+
+// indexedPlace is a polygon in the PIP database, kept to search for the
+// nearest one to points outside all of them.
+type indexedPlace struct {
+	id        int64
+	placetype string
+	bound     orb.Bound
+	current   bool
+	// area is the polygon's geom:area, used to choose between places of the
+	// same placetype containing a point
+	area float64
+}
+
+// Indexer builds the PIP database for a PIPClient from the features of the
+// admin data, leaving out deprecated features and those of any placetype it
+// wasn't created with. It's safe to add features from several goroutines at
+// once.
+type Indexer struct {
+	db         database.SpatialDatabase
+	placetypes []string
+
+	mu     sync.Mutex
+	places []*indexedPlace
+
+	indexed, skippedDeprecated, skippedPlacetype atomic.Uint64
+	// polygons and points count the size of the geometries indexed
+	polygons, points atomic.Uint64
+}
+
+// NewIndexer creates an Indexer for the polygons of the placetypes provided,
+// which are the only ones the PIPClient can then parent features by.
+func NewIndexer(ctx context.Context, placetypes []string) (*Indexer, error) {
+	err := validatePlacetypes(placetypes)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.NewRTreeSpatialDatabase(ctx, "rtree://")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Indexer{db: db, placetypes: placetypes}, nil
+}
+
+// Filter returns the admin features the Indexer needs, for admindata.Load.
+func (ix *Indexer) Filter() *admindata.Filter {
+	return &admindata.Filter{Placetypes: ix.placetypes, SkipDeprecated: true}
+}
+
+// AddFeature indexes the feature if it's one of the Indexer's placetypes and
+// isn't deprecated.
+func (ix *Indexer) AddFeature(ctx context.Context, f []byte) error {
+	placetype, err := properties.Placetype(f)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(ix.placetypes, placetype) {
+		ix.skippedPlacetype.Add(1)
+		return nil
+	}
+
+	if deprecated := properties.Deprecated(f); !edtf.IsUnspecified(deprecated) {
+		ix.skippedDeprecated.Add(1)
+		return nil
+	}
+
+	err = ix.db.IndexFeature(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	place, geom, err := newIndexedPlace(f, placetype)
+	if err != nil {
+		return err
+	}
+
+	polygons, points := geometrySize(geom)
+	ix.polygons.Add(polygons)
+	ix.points.Add(points)
+
+	ix.mu.Lock()
+	ix.places = append(ix.places, place)
+	ix.mu.Unlock()
+
+	ix.indexed.Add(1)
+	return nil
+}
+
+// Client returns a PIPClient for the features indexed, reading any others it
+// needs from the admin data at path, either a directory or a WOF SQLite
+// distribution. No more features should be added once
+// it's been called.
+func (ix *Indexer) Client(ctx context.Context, path string) (*PIPClient, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	log.Printf("Indexed %d %s features in the PIP database, skipping %d deprecated and %d of other placetypes", ix.indexed.Load(), strings.Join(ix.placetypes, "/"), ix.skippedDeprecated.Load(), ix.skippedPlacetype.Load())
+	log.Printf("PIP index holds %d polygons with %d points, process heap is %d MB", ix.polygons.Load(), ix.points.Load(), mem.HeapAlloc/1024/1024)
+
+	// Return every polygon containing the point, rather than just those of the
+	// nearest ancestor placetype, so the placetypes can be chosen between in
+	// order of precedence
+	options := &hierarchy.PointInPolygonHierarchyResolverOptions{Database: ix.db, SkipPlacetypeFilter: true}
+
+	resolver, err := hierarchy.NewPointInPolygonHierarchyResolver(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := admindata.NewReader(ctx, absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver.SetReader(r)
+
+	areas := make(map[int64]float64, len(ix.places))
+	for _, place := range ix.places {
+		areas[place.id] = place.area
+	}
+
+	return &PIPClient{database: ix.db, resolver: resolver, reader: r, placetypes: DefaultPlacetypes, places: ix.places, areas: areas}, nil
+}
+
+func newIndexedPlace(f []byte, placetype string) (*indexedPlace, orb.Geometry, error) {
+	id, err := properties.Id(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	geom, err := geometry.Geometry(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err := properties.IsCurrent(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	area := gjson.GetBytes(f, "properties.geom:area").Float()
+	if area == 0 {
+		area = planar.Area(geom.Geometry())
+	}
+
+	return &indexedPlace{
+		id:        id,
+		placetype: placetype,
+		bound:     geom.Geometry().Bound(),
+		current:   current.Flag() != 0,
+		area:      area,
+	}, geom.Geometry(), nil
+}
+
+// geometrySize returns the number of polygons in a geometry and the number of
+// points in their rings.
+func geometrySize(g orb.Geometry) (polygons uint64, points uint64) {
+	switch g := g.(type) {
+	case orb.Polygon:
+		for _, ring := range g {
+			points += uint64(len(ring))
+		}
+
+		return 1, points
+	case orb.MultiPolygon:
+		for _, p := range g {
+			_, n := geometrySize(p)
+			points += n
+		}
+
+		return uint64(len(g)), points
+	}
+
+	return 0, 0
+}
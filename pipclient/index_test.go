@@ -0,0 +1,81 @@
+package pipclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/tidwall/gjson"
+)
+
+func TestIndexSkips(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	writeSquare(t, root, 2001, "county", 2, `{"county_id":2001}`, "")
+	writeSquare(t, root, 2002, "locality", 1, `{"county_id":2001,"locality_id":2002}`, `"edtf:deprecated":"2020-01-01",`)
+	writeSquare(t, root, 2003, "neighbourhood", 1, `{"county_id":2001,"neighbourhood_id":2003}`, "")
+
+	client, err := NewPIPClientWithPlacetypes(ctx, root, []string{"locality", "county"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := client.UpdateHierarchyWithPlacetypes(ctx, []byte(`{"type":"Feature","properties":{"wof:name":"AB1 1AA","wof:placetype":"postalcode"},"geometry":{"type":"Point","coordinates":[0.5,0.5]}}`), "neighbourhood", "locality", "county")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parent := gjson.GetBytes(updated, "properties.wof:parent_id").Int(); parent != 2001 {
+		t.Errorf("parent = %d, want the county 2001", parent)
+	}
+}
+
+func TestIndexAreas(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	// The larger square claims the smaller area, which is the one that counts
+	writeSquare(t, root, 2004, "locality", 2, `{"locality_id":2004}`, `"geom:area":0.5,`)
+	writeSquare(t, root, 2005, "locality", 1, `{"locality_id":2005}`, "")
+
+	client, err := NewPIPClient(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if area := client.areas[2005]; area != 1 {
+		t.Errorf("area of 2005 = %g, want 1 from its geometry", area)
+	}
+
+	updated, err := client.UpdateHierarchy(ctx, []byte(`{"type":"Feature","properties":{"wof:name":"AB1 1AA","wof:placetype":"postalcode"},"geometry":{"type":"Point","coordinates":[0.5,0.5]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parent := gjson.GetBytes(updated, "properties.wof:parent_id").Int(); parent != 2004 {
+		t.Errorf("parent = %d, want 2004 with the smaller geom:area", parent)
+	}
+}
+
+func TestGeometrySize(t *testing.T) {
+	square := orb.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	withHole := orb.Polygon{square[0], {{0.2, 0.2}, {0.4, 0.2}, {0.4, 0.4}, {0.2, 0.2}}}
+
+	tests := []struct {
+		geom     orb.Geometry
+		polygons uint64
+		points   uint64
+	}{
+		{square, 1, 5},
+		{orb.MultiPolygon{square, withHole}, 2, 14},
+		{orb.Point{0, 0}, 0, 0},
+	}
+
+	for _, test := range tests {
+		polygons, points := geometrySize(test.geom)
+		if polygons != test.polygons || points != test.points {
+			t.Errorf("geometrySize(%s) = %d, %d, want %d, %d", test.geom.GeoJSONType(), polygons, points, test.polygons, test.points)
+		}
+	}
+}
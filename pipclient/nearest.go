@@ -0,0 +1,354 @@
+package pipclient
+
+import (
+	"container/list"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+	"github.com/tidwall/gjson"
+
+	reader "github.com/whosonfirst/go-reader"
+	wof_reader "github.com/whosonfirst/go-whosonfirst-reader"
+	spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+)
+
+// The length of a degree of latitude in metres
+const metresPerDegree = 111195.0
+
+// How many polygons parsed for the nearest fallback are kept, most recently
+// used first. Points near the coast come in runs along it, so this is plenty
+// to avoid parsing the same polygon over and over.
+const maxNearbyPlaces = 1000
+
+// Outcomes for the ParentReport
+const (
+	// OutcomeNearest is a feature parented by the nearest polygon, as its
+	// point isn't in any
+	OutcomeNearest = "nearest"
+	// OutcomeNoParent is a feature left without a parent
+	OutcomeNoParent = "no parent"
+)
+
+// ParentReport writes a CSV of the features whose parents were inferred from
+// the nearest polygon, or which have no parent at all. It's safe to use from
+// several goroutines at once.
+type ParentReport struct {
+	mu sync.Mutex
+	w  *csv.Writer
+}
+
+// NewParentReport writes the header row of the report CSV to out.
+func NewParentReport(out io.Writer) (*ParentReport, error) {
+	w := csv.NewWriter(out)
+
+	err := w.Write([]string{"wof_name", "latitude", "longitude", "outcome", "parent_id", "parent_placetype", "distance_m"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParentReport{w: w}, nil
+}
+
+func (r *ParentReport) write(name string, point orb.Point, outcome string, parent spr.StandardPlacesResult, distance float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := []string{
+		name,
+		strconv.FormatFloat(point.Lat(), 'f', 6, 64),
+		strconv.FormatFloat(point.Lon(), 'f', 6, 64),
+		outcome,
+		"", "", "",
+	}
+
+	if parent != nil {
+		row[4] = parent.Id()
+		row[5] = parent.Placetype()
+		row[6] = strconv.FormatFloat(distance, 'f', 0, 64)
+	}
+
+	return r.w.Write(row)
+}
+
+// Flush writes any buffered rows.
+func (r *ParentReport) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// EnableNearestFallback makes the client parent features whose points aren't
+// in any polygon of the placetypes by the nearest one within maxDistance
+// metres, such as for postcodes on the coast or a pier.
+func (client *PIPClient) EnableNearestFallback(maxDistance float64) error {
+	if maxDistance <= 0 {
+		return fmt.Errorf("invalid nearest polygon distance %f", maxDistance)
+	}
+
+	places := make([]rtreego.Spatial, len(client.places))
+	for i, place := range client.places {
+		places[i] = place
+	}
+
+	client.nearestDistance = maxDistance
+	client.nearbyTree = rtreego.NewTree(2, 25, 50, places...)
+	client.nearby = newNearbyCache(maxNearbyPlaces)
+	return nil
+}
+
+// SetParentReport sets a report to list every feature parented by the nearest
+// polygon, and every feature left without a parent.
+func (client *PIPClient) SetParentReport(report *ParentReport) {
+	client.report = report
+}
+
+// fallback is called for features which aren't in any polygon of the
+// placetypes, returning the nearest one if the fallback is enabled, and
+// reporting the outcome.
+func (client *PIPClient) fallback(ctx context.Context, r reader.Reader, body []byte, placetypes []string) (spr.StandardPlacesResult, error) {
+	if client.nearestDistance <= 0 && client.report == nil {
+		return nil, nil
+	}
+
+	centroid, err := client.resolver.PointInPolygonCentroid(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent spr.StandardPlacesResult
+	var distance float64
+
+	if client.nearestDistance > 0 {
+		parent, distance, err = client.nearest(ctx, r, *centroid, placetypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if client.report != nil {
+		outcome := OutcomeNoParent
+		if parent != nil {
+			outcome = OutcomeNearest
+		}
+
+		err = client.report.write(gjson.GetBytes(body, "properties.wof:name").String(), *centroid, outcome, parent, distance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parent, nil
+}
+
+// nearbyPlace is a polygon parsed for the nearest fallback.
+type nearbyPlace struct {
+	geometry orb.Geometry
+	parent   spr.StandardPlacesResult
+}
+
+// nearbyCache keeps the polygons parsed for the nearest fallback that were
+// used most recently, so that one is only read and parsed once however many
+// points in a row are near it, without holding every polygon in memory.
+type nearbyCache struct {
+	size int
+
+	mu     sync.Mutex
+	order  *list.List
+	places map[int64]*list.Element
+}
+
+type nearbyEntry struct {
+	id    int64
+	place *nearbyPlace
+}
+
+func newNearbyCache(size int) *nearbyCache {
+	return &nearbyCache{size: size, order: list.New(), places: make(map[int64]*list.Element)}
+}
+
+func (c *nearbyCache) get(id int64) *nearbyPlace {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.places[id]
+	if !ok {
+		return nil
+	}
+
+	c.order.MoveToFront(e)
+	return e.Value.(*nearbyEntry).place
+}
+
+// add caches the place, dropping the least recently used one if it's full.
+func (c *nearbyCache) add(id int64, place *nearbyPlace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.places[id]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.places[id] = c.order.PushFront(&nearbyEntry{id: id, place: place})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.places, oldest.Value.(*nearbyEntry).id)
+	}
+}
+
+// len returns the number of places cached.
+func (c *nearbyCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// nearbyPlace returns the parsed polygon with the ID provided, reading it the
+// first time it's needed.
+func (client *PIPClient) nearbyPlace(ctx context.Context, r reader.Reader, id int64) (*nearbyPlace, error) {
+	place := client.nearby.get(id)
+	if place != nil {
+		return place, nil
+	}
+
+	body, err := wof_reader.LoadBytes(ctx, r, id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := geojson.UnmarshalFeature(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %d: %w", id, err)
+	}
+
+	parent, err := spr.WhosOnFirstSPR(body)
+	if err != nil {
+		return nil, err
+	}
+
+	place = &nearbyPlace{geometry: f.Geometry, parent: parent}
+
+	client.nearby.add(id, place)
+
+	return place, nil
+}
+
+// nearest returns the nearest current polygon to the point within the
+// client's distance, trying each placetype in turn. Ties are broken by lowest
+// ID. It returns nil if there isn't one.
+func (client *PIPClient) nearest(ctx context.Context, r reader.Reader, point orb.Point, placetypes []string) (spr.StandardPlacesResult, float64, error) {
+	maxDistance := client.nearestDistance
+
+	latDelta := maxDistance / metresPerDegree
+	lonDelta := latDelta / math.Cos(point.Lat()*math.Pi/180)
+
+	search := rect(orb.Bound{
+		Min: orb.Point{point.Lon() - lonDelta, point.Lat() - latDelta},
+		Max: orb.Point{point.Lon() + lonDelta, point.Lat() + latDelta},
+	})
+
+	candidates := client.nearbyTree.SearchIntersect(search)
+
+	for _, pt := range placetypes {
+		var best *nearbyPlace
+		var bestID int64
+		bestDistance := math.Inf(1)
+
+		for _, c := range candidates {
+			place := c.(*indexedPlace)
+
+			if place.placetype != pt || !place.current {
+				continue
+			}
+
+			parsed, err := client.nearbyPlace(ctx, r, place.id)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			distance := DistanceMetres(parsed.geometry, point)
+
+			if distance > maxDistance {
+				continue
+			}
+
+			if distance < bestDistance || (distance == bestDistance && place.id < bestID) {
+				best = parsed
+				bestID = place.id
+				bestDistance = distance
+			}
+		}
+
+		if best != nil {
+			return best.parent, bestDistance, nil
+		}
+	}
+
+	return nil, 0, nil
+}
+
+// Bounds returns the bounding box of the place, for the nearest fallback's
+// rtree.
+func (place *indexedPlace) Bounds() rtreego.Rect {
+	return rect(place.bound)
+}
+
+func rect(b orb.Bound) rtreego.Rect {
+	// Both points have two dimensions, so this can't fail
+	r, _ := rtreego.NewRectFromPoints(rtreego.Point{b.Min.Lon(), b.Min.Lat()}, rtreego.Point{b.Max.Lon(), b.Max.Lat()})
+	return r
+}
+
+// DistanceMetres returns roughly how far the point is from the edge of the
+// polygon, treating the earth as flat around the point, which is close enough
+// over a few kilometres.
+func DistanceMetres(g orb.Geometry, point orb.Point) float64 {
+	scale := math.Cos(point.Lat() * math.Pi / 180)
+
+	toLocal := func(ring orb.Ring) orb.Ring {
+		local := make(orb.Ring, len(ring))
+		for i, p := range ring {
+			local[i] = orb.Point{(p.Lon() - point.Lon()) * scale * metresPerDegree, (p.Lat() - point.Lat()) * metresPerDegree}
+		}
+
+		return local
+	}
+
+	var local orb.MultiPolygon
+
+	switch g := g.(type) {
+	case orb.Polygon:
+		local = orb.MultiPolygon{polygonToLocal(g, toLocal)}
+	case orb.MultiPolygon:
+		for _, polygon := range g {
+			local = append(local, polygonToLocal(polygon, toLocal))
+		}
+	default:
+		return math.Inf(1)
+	}
+
+	return planar.DistanceFrom(local, orb.Point{0, 0})
+}
+
+func polygonToLocal(polygon orb.Polygon, toLocal func(orb.Ring) orb.Ring) orb.Polygon {
+	local := make(orb.Polygon, len(polygon))
+	for i, ring := range polygon {
+		local[i] = toLocal(ring)
+	}
+
+	return local
+}
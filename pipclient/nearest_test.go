@@ -0,0 +1,153 @@
+package pipclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/tidwall/gjson"
+
+	reader "github.com/whosonfirst/go-reader"
+)
+
+func TestNearestFallback(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	writeSquare(t, root, 3001, "locality", 1, `{"locality_id":3001}`, "")
+
+	client, err := NewPIPClient(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.EnableNearestFallback(500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	report, err := NewParentReport(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetParentReport(report)
+
+	tests := []struct {
+		name   string
+		lon    float64
+		parent int64
+	}{
+		{"AB1 1AA", 0.5, 3001},
+		{"AB1 1AB", 1.003, 3001},
+		{"AB1 1AD", 1.1, -1},
+	}
+
+	for _, test := range tests {
+		body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:name":"%s","wof:placetype":"postalcode"},"geometry":{"type":"Point","coordinates":[%g,0.5]}}`, test.name, test.lon)
+
+		updated, err := client.UpdateHierarchy(ctx, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parent := gjson.GetBytes(updated, "properties.wof:parent_id").Int(); parent != test.parent {
+			t.Errorf("parent of %s = %d, want %d", test.name, parent, test.parent)
+		}
+	}
+
+	err = report.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "wof_name,latitude,longitude,outcome,parent_id,parent_placetype,distance_m\n" +
+		"AB1 1AB,0.500000,1.003000,nearest,3001,locality,334\n" +
+		"AB1 1AD,0.500000,1.100000,no parent,,,\n"
+
+	if got := out.String(); got != want {
+		t.Errorf("report =\n%s\nwant\n%s", got, strings.TrimSpace(want))
+	}
+}
+
+// countingReader counts the features read through it.
+type countingReader struct {
+	reader.Reader
+	reads int
+}
+
+func (r *countingReader) Read(ctx context.Context, path string) (io.ReadSeekCloser, error) {
+	r.reads++
+	return r.Reader.Read(ctx, path)
+}
+
+func TestNearestCachesGeometries(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	writeSquare(t, root, 3001, "locality", 1, `{"locality_id":3001}`, "")
+
+	client, err := NewPIPClient(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.EnableNearestFallback(500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsReader, err := reader.NewReader(ctx, "fs://"+root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &countingReader{Reader: fsReader}
+
+	for _, lon := range []float64{1.001, 1.002, 1.003} {
+		parent, _, err := client.nearest(ctx, r, orb.Point{lon, 0.5}, []string{"locality"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parent == nil || parent.Id() != "3001" {
+			t.Errorf("expected 3001 to be nearest to %g, got %v", lon, parent)
+		}
+	}
+
+	if r.reads != 1 {
+		t.Errorf("expected the polygon to be read once, got %d reads", r.reads)
+	}
+}
+
+func TestNearbyCache(t *testing.T) {
+	c := newNearbyCache(2)
+
+	for id := int64(1); id <= 2; id++ {
+		c.add(id, &nearbyPlace{})
+	}
+
+	// Using 1 leaves 2 as the least recently used, to be dropped for 3
+	if c.get(1) == nil {
+		t.Fatal("expected 1 to be cached")
+	}
+
+	c.add(3, &nearbyPlace{})
+
+	if c.len() != 2 {
+		t.Errorf("expected 2 places cached, got %d", c.len())
+	}
+
+	if c.get(2) != nil {
+		t.Error("expected 2 to have been dropped")
+	}
+
+	if c.get(1) == nil || c.get(3) == nil {
+		t.Error("expected 1 and 3 to be cached")
+	}
+}
@@ -4,79 +4,197 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"strconv"
 
+	"github.com/dhconnelly/rtreego"
 	"github.com/whosonfirst/go-whosonfirst-spatial/database"
 	"github.com/whosonfirst/go-whosonfirst-spatial/filter"
 
 	reader "github.com/whosonfirst/go-reader"
+	"github.com/whosonfirst/go-whosonfirst-placetypes"
 	hierarchy "github.com/whosonfirst/go-whosonfirst-spatial/hierarchy"
 	spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/admindata"
 )
 
+// DefaultPlacetypes are the placetypes postcodes are parented by, in order of
+// precedence.
+var DefaultPlacetypes = []string{"locality", "localadmin", "county", "region"}
+
 type PIPClient struct {
-	database database.SpatialDatabase
-	resolver *hierarchy.PointInPolygonHierarchyResolver
+	database   database.SpatialDatabase
+	resolver   *hierarchy.PointInPolygonHierarchyResolver
+	reader     admindata.Reader
+	placetypes []string
+	cache      *cache
+	places     []*indexedPlace
+	// areas are the areas of the indexed polygons, by ID
+	areas map[int64]float64
+	// nearestDistance is how far in metres to look for the nearest polygon
+	// to points outside all of them, or zero not to
+	nearestDistance float64
+	// nearbyTree indexes the bounds of places for the nearest fallback
+	nearbyTree *rtreego.Rtree
+	nearby     *nearbyCache
+	report     *ParentReport
 }
 
+// NewPIPClient creates a PIPClient for the admin data at path, indexing the
+// polygons of the DefaultPlacetypes.
 func NewPIPClient(ctx context.Context, path string) (*PIPClient, error) {
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return nil, err
-	}
+	return NewPIPClientWithPlacetypes(ctx, path, DefaultPlacetypes)
+}
 
-	db, err := database.NewRTreeSpatialDatabase(ctx, "rtree://")
+// NewPIPClientWithPlacetypes creates a PIPClient for the admin data at path,
+// indexing only the polygons of the placetypes provided, which are the only
+// ones it can then parent features by.
+func NewPIPClientWithPlacetypes(ctx context.Context, path string, placetypes []string) (*PIPClient, error) {
+	ix, err := NewIndexer(ctx, placetypes)
 	if err != nil {
 		return nil, err
 	}
 
-	dir := os.DirFS(absPath)
-
 	log.Print("Indexing PIP database")
-	err = database.IndexDatabaseWithFS(ctx, db, dir)
+	err = admindata.Load(ctx, path, ix.Filter(), ix.AddFeature)
 	if err != nil {
 		return nil, err
 	}
 	log.Print("Indexing PIP database complete")
 
-	options := &hierarchy.PointInPolygonHierarchyResolverOptions{Database: db}
+	return ix.Client(ctx, path)
+}
+
+// Close closes the reader the client reads the admin data with.
+func (client *PIPClient) Close() error {
+	return client.reader.Close()
+}
 
-	resolver, err := hierarchy.NewPointInPolygonHierarchyResolver(ctx, options)
+// SetPlacetypes replaces the placetypes UpdateHierarchy parents postcodes by,
+// in order of precedence, e.g. neighbourhood, borough, locality.
+func (client *PIPClient) SetPlacetypes(types []string) error {
+	err := validatePlacetypes(types)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	readerUri := fmt.Sprintf("fs://%s", absPath)
-	r, err := reader.NewReader(ctx, readerUri)
-	if err != nil {
-		return nil, err
+	client.placetypes = types
+	return nil
+}
+
+func validatePlacetypes(types []string) error {
+	if len(types) == 0 {
+		return fmt.Errorf("no parent placetypes given")
 	}
 
-	resolver.SetReader(r)
+	for _, pt := range types {
+		if !placetypes.IsValidPlacetype(pt) {
+			return fmt.Errorf("invalid parent placetype %s", pt)
+		}
+	}
 
-	return &PIPClient{database: db, resolver: resolver}, nil
+	return nil
 }
 
+// UpdateHierarchy sets the hierarchy of a postcode from the polygon its point
+// is in, by default a locality, localadmin, county or region in that order.
 func (client *PIPClient) UpdateHierarchy(ctx context.Context, bytes []byte) ([]byte, error) {
-	inputs := &filter.SPRInputs{IsCurrent: []int64{-1, 1}}
+	// Only allow postalcode records in the admin hierarchy to be parented by
+	// these placetypes
+	return client.UpdateHierarchyWithPlacetypes(ctx, bytes, client.placetypes...)
+}
+
+// UpdateHierarchyWithPlacetypes sets the hierarchy of a feature from a polygon
+// its point is in with the earliest of the placetypes provided. If several
+// polygons of that placetype contain it, the smallest wins, then the one with
+// the lowest ID, so the parent doesn't depend on the order of the results.
+func (client *PIPClient) UpdateHierarchyWithPlacetypes(ctx context.Context, bytes []byte, placetypes ...string) ([]byte, error) {
+	if client.cache != nil {
+		return client.updateHierarchyCached(ctx, bytes, placetypes)
+	}
+
+	_, newBytes, err := client.resolve(ctx, bytes, placetypes)
+	return newBytes, err
+}
 
-	// Only allow postalcode records in the admin hierarchy to be parented by the
-	// following placetypes.
+// resolve does a full PIP for the feature, returning the properties it
+// assigned as well as the updated feature.
+func (client *PIPClient) resolve(ctx context.Context, bytes []byte, placetypes []string) (toAssign map[string]interface{}, newBytes []byte, err error) {
 	resultsCallback := func(ctx context.Context, r reader.Reader, body []byte, possible []spr.StandardPlacesResult) (spr.StandardPlacesResult, error) {
+		parent := choosePlace(possible, placetypes, client.area)
+
+		if parent == nil {
+			return client.fallback(ctx, r, body, placetypes)
+		}
+
+		return parent, nil
+	}
+
+	defaultUpdateCallback := hierarchy.DefaultPointInPolygonHierarchyResolverUpdateCallback()
+
+	updateCallback := func(ctx context.Context, r reader.Reader, parent spr.StandardPlacesResult) (map[string]interface{}, error) {
+		assign, err := defaultUpdateCallback(ctx, r, parent)
+		toAssign = assign
+		return assign, err
+	}
+
+	_, newBytes, err = client.resolver.PointInPolygonAndUpdate(ctx, sprInputs(), resultsCallback, updateCallback, bytes)
+	return toAssign, newBytes, err
+}
+
+func sprInputs() *filter.SPRInputs {
+	return &filter.SPRInputs{IsCurrent: []int64{-1, 1}}
+}
+
+// choosePlace returns the result with the earliest of the placetypes, breaking
+// ties by smallest area and then lowest ID, or nil if none have them.
+func choosePlace(possible []spr.StandardPlacesResult, placetypes []string, areaFn func(spr.StandardPlacesResult) float64) spr.StandardPlacesResult {
+	for _, pt := range placetypes {
+		var candidates []spr.StandardPlacesResult
+
 		for _, item := range possible {
-			switch item.Placetype() {
-			case "locality", "localadmin", "county", "region":
-				return item, nil
+			if item.Placetype() == pt {
+				candidates = append(candidates, item)
 			}
 		}
 
-		return nil, nil
+		if len(candidates) == 0 {
+			continue
+		}
+
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+
+		areas := make([]float64, len(candidates))
+		for i, c := range candidates {
+			areas[i] = areaFn(c)
+		}
+
+		best := 0
+		for i := 1; i < len(candidates); i++ {
+			if areas[i] < areas[best] || (areas[i] == areas[best] && parseID(candidates[i]) < parseID(candidates[best])) {
+				best = i
+			}
+		}
+
+		return candidates[best]
 	}
 
-	updateCallback := hierarchy.DefaultPointInPolygonHierarchyResolverUpdateCallback()
+	return nil
+}
+
+// area returns the area of a result recorded when it was indexed, or the area
+// of its bounding box if it wasn't.
+func (client *PIPClient) area(item spr.StandardPlacesResult) float64 {
+	if a, ok := client.areas[parseID(item)]; ok {
+		return a
+	}
 
-	_, newBytes, err := client.resolver.PointInPolygonAndUpdate(ctx, inputs, resultsCallback, updateCallback, bytes)
+	return (item.MaxLatitude() - item.MinLatitude()) * (item.MaxLongitude() - item.MinLongitude())
+}
 
-	return newBytes, err
+func parseID(item spr.StandardPlacesResult) int64 {
+	id, _ := strconv.ParseInt(item.Id(), 10, 64)
+	return id
 }
@@ -3,6 +3,8 @@ package pipclient
 import (
 	"context"
 	"testing"
+
+	spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
 )
 
 func TestPIPClient(t *testing.T) {
@@ -18,3 +20,54 @@ func TestPIPClient(t *testing.T) {
 	}
 
 }
+
+type testPlace struct {
+	spr.StandardPlacesResult
+	id        string
+	placetype string
+}
+
+func (p *testPlace) Id() string {
+	return p.id
+}
+
+func (p *testPlace) Placetype() string {
+	return p.placetype
+}
+
+func TestChoosePlace(t *testing.T) {
+	areas := map[string]float64{"1": 2, "2": 1, "3": 1, "4": 5, "5": 9}
+
+	possible := []spr.StandardPlacesResult{
+		&testPlace{id: "5", placetype: "region"},
+		&testPlace{id: "1", placetype: "locality"},
+		&testPlace{id: "3", placetype: "locality"},
+		&testPlace{id: "2", placetype: "locality"},
+		&testPlace{id: "4", placetype: "county"},
+	}
+
+	areaFn := func(item spr.StandardPlacesResult) float64 {
+		return areas[item.Id()]
+	}
+
+	tests := []struct {
+		placetypes []string
+		want       string
+	}{
+		{[]string{"locality", "county", "region"}, "2"},
+		{[]string{"neighbourhood", "county", "locality"}, "4"},
+		{[]string{"region"}, "5"},
+		{[]string{"borough"}, ""},
+	}
+
+	for _, test := range tests {
+		got := ""
+		if place := choosePlace(possible, test.placetypes, areaFn); place != nil {
+			got = place.Id()
+		}
+
+		if got != test.want {
+			t.Errorf("choosePlace(%v) = %q, want %q", test.placetypes, got, test.want)
+		}
+	}
+}
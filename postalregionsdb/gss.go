@@ -0,0 +1,92 @@
+package postalregionsdb
+
+import (
+	"regexp"
+
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+)
+
+// GSS codes are a letter for the country, two digits for the kind of area and
+// six digits for the area itself
+var gssRegexp = regexp.MustCompile(`^[EWSNKLM]\d{8}$`)
+
+// The placetypes of the admin records matched to the ONS ctry, rgn, oscty and
+// oslaua codes
+var gssPlacetypes = map[string]bool{
+	"country":     true,
+	"macroregion": true,
+	"region":      true,
+	"macrocounty": true,
+	"county":      true,
+	"localadmin":  true,
+}
+
+// GSSRecord is an admin record with a GSS code in its wof:concordances.
+type GSSRecord struct {
+	WofID     int64
+	Placetype string
+	Hierarchy []map[string]int64
+	// Superseded is set if the record has a wof:superseded_by
+	Superseded bool
+}
+
+// LookupGSS returns the admin record with the GSS code provided, or nil if
+// there isn't one. A superseded record is swapped for the one superseding it,
+// as the ONS codes can lag behind boundary changes.
+func (db *PostalRegionsDB) LookupGSS(code string) *GSSRecord {
+	record := db.gss[code]
+	if record == nil || !record.Superseded {
+		return record
+	}
+
+	if current := db.admin[db.current(record.WofID)]; current != nil {
+		return current
+	}
+
+	return record
+}
+
+// addGSSCodes records any concordances of the feature which are GSS codes,
+// whatever their key. If several records have the same code, one which hasn't
+// been superseded wins, then the one with the lowest ID.
+func (db *PostalRegionsDB) addGSSCodes(f []byte, placetype string) error {
+	id, err := properties.Id(f)
+	if err != nil {
+		return err
+	}
+
+	record := &GSSRecord{
+		WofID:      id,
+		Placetype:  placetype,
+		Hierarchy:  properties.Hierarchies(f),
+		Superseded: len(properties.SupersededBy(f)) > 0,
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.admin[id] = record
+
+	for _, value := range properties.Concordances(f) {
+		code, ok := value.(string)
+		if !ok || !gssRegexp.MatchString(code) {
+			continue
+		}
+
+		if existing := db.gss[code]; existing == nil || preferGSSRecord(record, existing) {
+			db.gss[code] = record
+		}
+	}
+
+	return nil
+}
+
+// preferGSSRecord returns whether record should replace existing for a GSS
+// code they share.
+func preferGSSRecord(record *GSSRecord, existing *GSSRecord) bool {
+	if record.Superseded != existing.Superseded {
+		return existing.Superseded
+	}
+
+	return record.WofID < existing.WofID
+}
@@ -1,88 +1,195 @@
 package postalregionsdb
 
 import (
-	"fmt"
-	"os"
-	"strings"
+	"context"
+	"slices"
 	"sync"
 
-	"github.com/saracen/walker"
+	"github.com/tidwall/gjson"
 	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/admindata"
 )
 
 type PostalRegion struct {
 	Name      string
 	WofID     int64
 	Hierarchy []map[string]int64
+	// Cessation is the EDTF edtf:cessation of the feature
+	Cessation string
 }
 
+// PostalSectorPlacetype is the wof:placetype_alt of postcode sectors, which
+// have a wof:placetype of custom as there's no placetype for them.
+const PostalSectorPlacetype = "postalsector"
+
 type PostalRegionsDB struct {
 	dataPath *string
 	Regions  map[string]*PostalRegion
+	// Sectors holds the postcode sectors, e.g. SW1A 1, keyed by name
+	Sectors map[string]*PostalRegion
+	// gss maps GSS codes, e.g. E10000002, to the admin records with them
+	gss map[string]*GSSRecord
+	// admin maps the IDs of the admin records matched to GSS codes to them
+	admin map[int64]*GSSRecord
+	// supersededBy maps the IDs of superseded admin records to their
+	// wof:superseded_by
+	supersededBy map[int64][]int64
+	// mu guards the maps while features are added
+	mu sync.Mutex
 }
 
 func NewPostalRegionsDB(dataPath string) *PostalRegionsDB {
-	db := &PostalRegionsDB{dataPath: &dataPath, Regions: make(map[string]*PostalRegion)}
+	db := &PostalRegionsDB{dataPath: &dataPath, Regions: make(map[string]*PostalRegion), Sectors: make(map[string]*PostalRegion), gss: make(map[string]*GSSRecord), admin: make(map[int64]*GSSRecord), supersededBy: make(map[int64][]int64)}
 
 	return db
 }
 
+// AddRegion registers a postalregion. It isn't safe to call while other
+// goroutines are reading Regions.
+func (db *PostalRegionsDB) AddRegion(region *PostalRegion) {
+	db.Regions[region.Name] = region
+}
+
+// AddSector registers a postcode sector. It isn't safe to call while other
+// goroutines are reading Sectors.
+func (db *PostalRegionsDB) AddSector(sector *PostalRegion) {
+	db.Sectors[sector.Name] = sector
+}
+
+// Build loads the admin data at the PostalRegionsDB's path. Use AddFeature
+// instead to share a single pass over it with other lookups.
 func (db *PostalRegionsDB) Build() error {
-	var mutex = &sync.RWMutex{}
+	return admindata.Load(context.Background(), *db.dataPath, db.Filter(), db.AddFeature)
+}
 
-	walkFn := func(path string, fi os.FileInfo) error {
-		if fi.IsDir() {
-			return nil
-		}
+// Filter returns the admin features the PostalRegionsDB needs, for
+// admindata.Load. Deprecated features are kept, as GSS codes found only on
+// superseded records are resolved through them.
+func (db *PostalRegionsDB) Filter() *admindata.Filter {
+	placetypes := []string{"postalregion", "custom"}
+	for pt := range gssPlacetypes {
+		placetypes = append(placetypes, pt)
+	}
 
-		if !strings.HasSuffix(path, ".geojson") {
-			return nil
-		}
+	slices.Sort(placetypes)
+	return &admindata.Filter{Placetypes: placetypes}
+}
 
-		if strings.Contains(path, "alt") {
-			return nil
-		}
+// AddFeature registers the feature if it's a postalregion or postcode sector,
+// records its GSS codes if it's an admin record they're matched to, and
+// records what supersedes it. It's safe to call from several goroutines at
+// once.
+func (db *PostalRegionsDB) AddFeature(ctx context.Context, f []byte) error {
+	placetype, err := properties.Placetype(f)
+	if err != nil {
+		return err
+	}
 
-		f, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
+	err = db.addSupersession(f)
+	if err != nil {
+		return err
+	}
 
-		placetype, err := properties.Placetype(f)
-		if err != nil {
-			return err
-		}
+	if gssPlacetypes[placetype] {
+		return db.addGSSCodes(f, placetype)
+	}
 
-		if placetype != "postalregion" {
-			return nil
-		}
+	isSector := placetype == "custom" && hasPlacetypeAlt(f, PostalSectorPlacetype)
 
-		name, err := properties.Name(f)
-		if err != nil {
-			return err
+	if placetype != "postalregion" && !isSector {
+		return nil
+	}
+
+	name, err := properties.Name(f)
+	if err != nil {
+		return err
+	}
+
+	id, err := properties.Id(f)
+	if err != nil {
+		return err
+	}
+
+	hierarchy := properties.Hierarchies(f)
+
+	region := &PostalRegion{
+		Name:      name,
+		WofID:     id,
+		Hierarchy: hierarchy,
+		Cessation: properties.Cessation(f),
+	}
+
+	db.mu.Lock()
+	if isSector {
+		db.Sectors[name] = region
+	} else {
+		db.Regions[name] = region
+	}
+	db.mu.Unlock()
+
+	return nil
+}
+
+func hasPlacetypeAlt(f []byte, placetype string) bool {
+	for _, alt := range gjson.GetBytes(f, "properties.wof:placetype_alt").Array() {
+		if alt.String() == placetype {
+			return true
 		}
+	}
 
-		id, err := properties.Id(f)
-		if err != nil {
-			return err
+	return false
+}
+
+// ChooseHierarchy returns the hierarchy of the postalregion which agrees with
+// the most of the counties and regions given, a matching county counting for
+// more than a matching region. It's ambiguous if the postalregion has several
+// hierarchies and more than one of them, or none, agree the most, in which
+// case the first of those is returned.
+func (r *PostalRegion) ChooseHierarchy(counties []int64, regions []int64) (hierarchy map[string]int64, ambiguous bool) {
+	if len(r.Hierarchy) == 0 {
+		return nil, false
+	}
+
+	if len(r.Hierarchy) == 1 {
+		return r.Hierarchy[0], false
+	}
+
+	best := -1
+	tied := 0
+
+	for _, h := range r.Hierarchy {
+		score := 0
+		if containsID(counties, h["county_id"]) {
+			score += 2
 		}
 
-		hierarchy := properties.Hierarchies(f)
+		if containsID(regions, h["region_id"]) {
+			score++
+		}
 
-		mutex.Lock()
-		db.Regions[name] = &PostalRegion{
-			Name:      name,
-			WofID:     id,
-			Hierarchy: hierarchy,
+		if score > best {
+			best = score
+			tied = 1
+			hierarchy = h
+		} else if score == best {
+			tied++
 		}
-		mutex.Unlock()
+	}
 
-		return nil
+	return hierarchy, best == 0 || tied > 1
+}
+
+func containsID(ids []int64, id int64) bool {
+	if id <= 0 {
+		return false
 	}
 
-	errorFn := walker.WithErrorCallback(func(path string, err error) error {
-		return fmt.Errorf("failed to read file %s: %w", path, err)
-	})
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
 
-	return walker.Walk(*db.dataPath, walkFn, errorFn)
+	return false
 }
@@ -0,0 +1,116 @@
+package postalregionsdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChooseHierarchy(t *testing.T) {
+	region := &PostalRegion{
+		Name: "TD15",
+		Hierarchy: []map[string]int64{
+			{"county_id": 1, "region_id": 10},
+			{"county_id": 2, "region_id": 20},
+			{"county_id": 3, "region_id": 20},
+		},
+	}
+
+	tests := []struct {
+		counties  []int64
+		regions   []int64
+		county    int64
+		ambiguous bool
+	}{
+		{[]int64{2}, []int64{20}, 2, false},
+		{[]int64{3}, nil, 3, false},
+		{nil, []int64{10}, 1, false},
+		{nil, []int64{20}, 2, true},
+		{nil, nil, 1, true},
+		{[]int64{4}, []int64{30}, 1, true},
+	}
+
+	for _, test := range tests {
+		h, ambiguous := region.ChooseHierarchy(test.counties, test.regions)
+		if h["county_id"] != test.county || ambiguous != test.ambiguous {
+			t.Errorf("ChooseHierarchy(%v, %v) = county %d, ambiguous %t, want county %d, ambiguous %t", test.counties, test.regions, h["county_id"], ambiguous, test.county, test.ambiguous)
+		}
+	}
+
+	single := &PostalRegion{Hierarchy: []map[string]int64{{"county_id": 1}}}
+	if _, ambiguous := single.ChooseHierarchy(nil, nil); ambiguous {
+		t.Error("single hierarchy shouldn't be ambiguous")
+	}
+}
+
+func TestLookupGSS(t *testing.T) {
+	root := t.TempDir()
+
+	features := map[string]string{
+		"county.geojson":   `{"properties":{"wof:id":10,"wof:name":"Kent","wof:placetype":"county","wof:concordances":{"gp:id":12345,"uk:gss":"E10000016"},"wof:hierarchy":[{"county_id":10,"region_id":20}]}}`,
+		"locality.geojson": `{"properties":{"wof:id":11,"wof:name":"Maidstone","wof:placetype":"locality","wof:concordances":{"uk:gss":"E07000110"}}}`,
+	}
+
+	for name, body := range features {
+		err := os.WriteFile(filepath.Join(root, name), []byte(body), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := NewPostalRegionsDB(root)
+
+	err := db.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := db.LookupGSS("E10000016")
+	if record == nil || record.WofID != 10 || record.Placetype != "county" || record.Hierarchy[0]["region_id"] != 20 {
+		t.Errorf("LookupGSS(E10000016) = %+v, want the county", record)
+	}
+
+	if record := db.LookupGSS("E07000110"); record != nil {
+		t.Errorf("LookupGSS(E07000110) = %+v, want nil for a locality", record)
+	}
+}
+
+func TestSupersession(t *testing.T) {
+	db := NewPostalRegionsDB("")
+
+	features := []string{
+		`{"properties":{"wof:id":1,"wof:name":"Old Kent","wof:placetype":"county","wof:concordances":{"uk:gss":"E10000016"},"wof:superseded_by":[5]}}`,
+		`{"properties":{"wof:id":5,"wof:name":"Kent","wof:placetype":"county","wof:superseded_by":[7]}}`,
+		`{"properties":{"wof:id":7,"wof:name":"Kent","wof:placetype":"county","wof:concordances":{"uk:gss":"E10000016"}}}`,
+		`{"properties":{"wof:id":8,"wof:name":"Split","wof:placetype":"county","wof:concordances":{"uk:gss":"E10000099"},"wof:superseded_by":[9,10]}}`,
+		`{"properties":{"wof:id":11,"wof:name":"Shepway","wof:placetype":"localadmin","wof:concordances":{"uk:gss":"E07000112"},"wof:superseded_by":[12]}}`,
+		`{"properties":{"wof:id":12,"wof:name":"Folkestone and Hythe","wof:placetype":"localadmin","wof:concordances":{"uk:gss":"E07000248"}}}`,
+	}
+
+	for _, f := range features {
+		err := db.AddFeature(context.Background(), []byte(f))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[int64]int64{1: 7, 5: 7, 7: 7, 8: 8}
+	for id, expected := range tests {
+		if current := db.current(id); current != expected {
+			t.Errorf("current(%d) = %d, want %d", id, current, expected)
+		}
+	}
+
+	if record := db.LookupGSS("E10000016"); record == nil || record.WofID != 7 {
+		t.Errorf("LookupGSS(E10000016) = %+v, want the record which isn't superseded", record)
+	}
+
+	if record := db.LookupGSS("E07000112"); record == nil || record.WofID != 12 {
+		t.Errorf("LookupGSS(E07000112) = %+v, want the record superseding it", record)
+	}
+
+	if record := db.LookupGSS("E10000099"); record == nil || record.WofID != 8 {
+		t.Errorf("LookupGSS(E10000099) = %+v, want the split record itself", record)
+	}
+}
@@ -0,0 +1,41 @@
+package postalregionsdb
+
+import (
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+)
+
+// addSupersession records which records supersede the feature, if any.
+func (db *PostalRegionsDB) addSupersession(f []byte) error {
+	supersededBy := properties.SupersededBy(f)
+	if len(supersededBy) == 0 {
+		return nil
+	}
+
+	id, err := properties.Id(f)
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	db.supersededBy[id] = supersededBy
+	db.mu.Unlock()
+
+	return nil
+}
+
+// current follows the chain of records superseding the admin record with the
+// ID provided, returning the last one. Records split into several aren't
+// followed, as there's no telling which of them is meant.
+func (db *PostalRegionsDB) current(id int64) int64 {
+	seen := map[int64]bool{id: true}
+
+	for {
+		next := db.supersededBy[id]
+		if len(next) != 1 || seen[next[0]] {
+			return id
+		}
+
+		id = next[0]
+		seen[id] = true
+	}
+}
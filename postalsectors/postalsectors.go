@@ -0,0 +1,155 @@
+package postalsectors
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"github.com/paulmach/orb"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/hull"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodevalidator"
+)
+
+// Sector is a postcode sector, e.g. SW1A 1, summarised from its unit
+// postcodes.
+type Sector struct {
+	Name    string
+	Outward string
+	// Inception is the earliest inception of its units, in the YYYYMM format
+	// of the ONS data, or empty if none have one
+	Inception string
+	// Cessation is empty while any of its units are live, and the latest
+	// cessation of its units otherwise
+	Cessation string
+	// Units is the number of unit postcodes in the sector
+	Units int
+	// CountyCodes and RegionCodes are the GSS codes of its units' counties
+	// and regions, used to choose between its postalregion's hierarchies
+	CountyCodes []string
+	RegionCodes []string
+
+	livePoints []orb.Point
+	allPoints  []orb.Point
+	live       bool
+}
+
+// Group summarises the unit postcodes in the source into sectors, keyed by
+// name. Special and overseas territory postcodes aren't in any sector. The
+// locations of Northern Ireland (BT) postcodes are left out of the sectors'
+// geometries unless ignoreRestrictiveLicence is set, as they're under a more
+// restrictive licence.
+func Group(source postcodesource.PostcodeSource, ignoreRestrictiveLicence bool) (map[string]*Sector, error) {
+	sectors := make(map[string]*Sector)
+	mu := sync.Mutex{}
+
+	err := source.Iterate(func(pc *postcodesource.Record) error {
+		parsed, err := postcodevalidator.Parse(pc.Postcode)
+		if err != nil || parsed.Sector == "" {
+			return nil
+		}
+
+		name := parsed.SectorName()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		sector := sectors[name]
+		if sector == nil {
+			sector = &Sector{Name: name, Outward: parsed.Outward()}
+			sectors[name] = sector
+		}
+
+		sector.add(pc, ignoreRestrictiveLicence || parsed.Area != "BT")
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sectors, nil
+}
+
+// add counts the unit postcode in the sector, using its location only if
+// usePoint is set.
+func (s *Sector) add(pc *postcodesource.Record, usePoint bool) {
+	s.Units++
+
+	if pc.CountyCode != "" && !slices.Contains(s.CountyCodes, pc.CountyCode) {
+		s.CountyCodes = append(s.CountyCodes, pc.CountyCode)
+	}
+
+	if pc.RegionCode != "" && !slices.Contains(s.RegionCodes, pc.RegionCode) {
+		s.RegionCodes = append(s.RegionCodes, pc.RegionCode)
+	}
+
+	if pc.Inception != "" && (s.Inception == "" || pc.Inception < s.Inception) {
+		s.Inception = pc.Inception
+	}
+
+	point, hasPoint := pc.Point()
+	hasPoint = hasPoint && usePoint
+
+	if pc.Cessation == "" {
+		s.live = true
+		s.Cessation = ""
+
+		if hasPoint {
+			s.livePoints = append(s.livePoints, point)
+		}
+	} else if !s.live && pc.Cessation > s.Cessation {
+		s.Cessation = pc.Cessation
+	}
+
+	if hasPoint {
+		s.allPoints = append(s.allPoints, point)
+	}
+}
+
+// Geometry returns the convex hull of the sector's live units, or their
+// centroid if they don't make a polygon. Sectors with no live units use all
+// of their units instead. It returns nil if none of the units have a
+// location.
+func (s *Sector) Geometry() orb.Geometry {
+	points := s.livePoints
+	if len(points) == 0 {
+		points = s.allPoints
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	// The points are in the order the source iterated them, so sort them for
+	// the same result every time
+	points = slices.Clone(points)
+	slices.SortFunc(points, func(a, b orb.Point) int {
+		if c := cmp.Compare(a.X(), b.X()); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Y(), b.Y())
+	})
+
+	if ring := hull.Convex(points); ring != nil {
+		return orb.Polygon{ring}
+	}
+
+	return centroid(points)
+}
+
+func centroid(points []orb.Point) orb.Point {
+	var c orb.Point
+
+	for _, p := range points {
+		c[0] += p[0]
+		c[1] += p[1]
+	}
+
+	c[0] /= float64(len(points))
+	c[1] /= float64(len(points))
+
+	return c
+}
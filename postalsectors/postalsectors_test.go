@@ -0,0 +1,99 @@
+package postalsectors
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/paulmach/orb"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+func TestGroup(t *testing.T) {
+	source := postcodesource.NewMemorySource(
+		&postcodesource.Record{Postcode: "SW1A 1AA", Latitude: "51.5", Longitude: "-0.14", Inception: "199001"},
+		&postcodesource.Record{Postcode: "SW1A 1AB", Latitude: "51.5", Longitude: "-0.13", Inception: "198001"},
+		&postcodesource.Record{Postcode: "SW1A 1AD", Latitude: "51.51", Longitude: "-0.14", Inception: "200001"},
+		&postcodesource.Record{Postcode: "SW1A 1AE", Latitude: "51.52", Longitude: "-0.15", Inception: "200001", Cessation: "201001"},
+		&postcodesource.Record{Postcode: "SW1A 2AA", Latitude: "51.5", Longitude: "-0.12", Inception: "198001", Cessation: "200001"},
+		&postcodesource.Record{Postcode: "SW1A 2AB", Latitude: "51.5", Longitude: "-0.12", Inception: "198001", Cessation: "200501"},
+	)
+
+	sectors, err := Group(source, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := sectors["SW1A 1"]
+	if live == nil || live.Units != 4 || live.Inception != "198001" || live.Cessation != "" {
+		t.Errorf("unexpected live sector %+v", live)
+	}
+
+	if _, ok := live.Geometry().(orb.Polygon); !ok {
+		t.Errorf("expected a polygon for the live sector, got %v", live.Geometry())
+	}
+
+	ceased := sectors["SW1A 2"]
+	if ceased == nil || ceased.Cessation != "200501" {
+		t.Errorf("unexpected ceased sector %+v", ceased)
+	}
+
+	if _, ok := ceased.Geometry().(orb.Point); !ok {
+		t.Errorf("expected a point for the ceased sector, got %v", ceased.Geometry())
+	}
+}
+
+func TestGroupRestrictiveLicence(t *testing.T) {
+	source := postcodesource.NewMemorySource(
+		&postcodesource.Record{Postcode: "BT1 1AA", Latitude: "54.6", Longitude: "-5.93"},
+		&postcodesource.Record{Postcode: "BT1 1AB", Latitude: "54.61", Longitude: "-5.92"},
+		&postcodesource.Record{Postcode: "BT1 1AD", Latitude: "54.6", Longitude: "-5.91"},
+	)
+
+	sectors, err := Group(source, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sector := sectors["BT1 1"]
+	if sector == nil || sector.Units != 3 {
+		t.Fatalf("unexpected sector %+v", sector)
+	}
+
+	if g := sector.Geometry(); g != nil {
+		t.Errorf("expected no geometry from Northern Ireland postcodes, got %v", g)
+	}
+
+	sectors, err = Group(source, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := sectors["BT1 1"].Geometry().(orb.Polygon); !ok {
+		t.Errorf("expected a polygon when ignoring the licence, got %v", sectors["BT1 1"].Geometry())
+	}
+}
+
+func TestGeometryOrder(t *testing.T) {
+	// Points in a line make no polygon, so their centroid is used, which
+	// sums them in a different order if they aren't sorted
+	points := []orb.Point{{0.1, 0.1}, {0.7, 0.7}, {0.2, 0.2}, {0.3, 0.3}, {1e-17, 1e-17}}
+
+	var want orb.Point
+
+	for i := 0; i < len(points); i++ {
+		rotated := append(slices.Clone(points[i:]), points[:i]...)
+		sector := &Sector{livePoints: rotated}
+
+		got, ok := sector.Geometry().(orb.Point)
+		if !ok {
+			t.Fatalf("expected a point for points in a line, got %v", sector.Geometry())
+		}
+
+		if i == 0 {
+			want = got
+		} else if got != want {
+			t.Errorf("Geometry() = %v for points in a different order, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package postcodesource
+
+// MemorySource is a PostcodeSource holding its Records in memory, such as for
+// tests.
+type MemorySource struct {
+	records map[string]*Record
+}
+
+// NewMemorySource returns a MemorySource holding the Records provided, keyed
+// by their postcodes.
+func NewMemorySource(records ...*Record) *MemorySource {
+	s := &MemorySource{records: make(map[string]*Record, len(records))}
+
+	for _, pc := range records {
+		s.records[pc.Postcode] = pc
+	}
+
+	return s
+}
+
+// Lookup returns the Record for the postcode provided, or nil if there isn't
+// one.
+func (s *MemorySource) Lookup(postcode string) (*Record, error) {
+	return s.records[postcode], nil
+}
+
+// Iterate fires the callback for every Record, from several goroutines at
+// once.
+func (s *MemorySource) Iterate(cb func(*Record) error) error {
+	return IterateRecords(s.records, cb)
+}
+
+// Metadata describes the MemorySource.
+func (s *MemorySource) Metadata() *Metadata {
+	return &Metadata{Name: "memory", Count: len(s.records)}
+}
+
+// Covers returns true for every postcode.
+func (s *MemorySource) Covers(postcode string) bool {
+	return true
+}
@@ -0,0 +1,179 @@
+package postcodesource
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+
+	"github.com/paulmach/orb"
+	"golang.org/x/sync/errgroup"
+)
+
+// Keys for the standard values of a Record. They're named after the ONSPD
+// columns the values come from, and other sources map their data on to them.
+const (
+	KeyPostcode          = "pcds"
+	KeyLatitude          = "lat"
+	KeyLongitude         = "long"
+	KeyInception         = "dointr"
+	KeyCessation         = "doterm"
+	KeyCountry           = "ctry"
+	KeyRegion            = "rgn"
+	KeyCounty            = "oscty"
+	KeyDistrict          = "oslaua"
+	KeyPositionalQuality = "osgrdind"
+)
+
+// The coordinates of a postcode without a location, following the ONSPD
+const (
+	NoLocationLatitude  = "99.999999"
+	NoLocationLongitude = "0.000000"
+)
+
+// Record represents an individual postcode with its associated data. Dates
+// are in the form YYYYMM, and codes are GSS codes.
+type Record struct {
+	Postcode          string
+	Latitude          string
+	Longitude         string
+	Inception         string
+	Cessation         string
+	CountryCode       string
+	RegionCode        string
+	CountyCode        string
+	DistrictCode      string
+	PositionalQuality string
+	// Extra holds any other values the source provides, keyed by the name
+	// of the column they were read from
+	Extra map[string]string
+}
+
+// Value returns the value with the key provided.
+func (r *Record) Value(key string) string {
+	switch key {
+	case KeyPostcode:
+		return r.Postcode
+	case KeyLatitude:
+		return r.Latitude
+	case KeyLongitude:
+		return r.Longitude
+	case KeyInception:
+		return r.Inception
+	case KeyCessation:
+		return r.Cessation
+	case KeyCountry:
+		return r.CountryCode
+	case KeyRegion:
+		return r.RegionCode
+	case KeyCounty:
+		return r.CountyCode
+	case KeyDistrict:
+		return r.DistrictCode
+	case KeyPositionalQuality:
+		return r.PositionalQuality
+	}
+
+	return r.Extra[key]
+}
+
+// Point returns the location of the postcode, and false if it doesn't have
+// one.
+func (r *Record) Point() (orb.Point, bool) {
+	if r.Latitude == NoLocationLatitude {
+		return orb.Point{}, false
+	}
+
+	latitude, err := strconv.ParseFloat(r.Latitude, 64)
+	if err != nil {
+		return orb.Point{}, false
+	}
+
+	longitude, err := strconv.ParseFloat(r.Longitude, 64)
+	if err != nil {
+		return orb.Point{}, false
+	}
+
+	return orb.Point{longitude, latitude}, true
+}
+
+// Metadata describes a PostcodeSource.
+type Metadata struct {
+	// Name is the name of the dataset, e.g. ONSPD
+	Name string
+	// Path is where the data was loaded from
+	Path string
+	// Licence is the licence the data is published under
+	Licence string
+	// Count is the number of postcodes in the source
+	Count int
+	// Columns lists the keys the source has values for, or is nil if it has
+	// every ONSPD column
+	Columns []string
+}
+
+// PostcodeSource is a dataset of postcodes the sync can be run against.
+type PostcodeSource interface {
+	// Lookup returns the Record for the postcode provided, or nil if the
+	// source doesn't have it.
+	Lookup(postcode string) (*Record, error)
+	// Iterate fires the callback for every Record in the source. The
+	// callback may be called concurrently.
+	Iterate(cb func(*Record) error) error
+	// Metadata describes the source.
+	Metadata() *Metadata
+	// Covers returns whether the postcode is in an area the source covers,
+	// so that its absence from the source means it's been terminated.
+	Covers(postcode string) bool
+}
+
+// Area returns the postcode area, the letters at the start of the postcode,
+// e.g. SW for SW1A 1AA.
+func Area(postcode string) string {
+	i := 0
+	for i < len(postcode) && postcode[i] >= 'A' && postcode[i] <= 'Z' {
+		i++
+	}
+
+	return postcode[:i]
+}
+
+// IterateRecords fires the callback for every Record in the map, from several
+// goroutines at once. Sources which hold their Records in memory can use it to
+// implement Iterate.
+func IterateRecords(records map[string]*Record, cb func(*Record) error) error {
+	workerCount := runtime.NumCPU() * 2
+	workChan := make(chan *Record, workerCount*2)
+
+	g, ctx := errgroup.WithContext(context.Background())
+
+	for i := 0; i < workerCount; i++ {
+		g.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+
+				case pc, more := <-workChan:
+					if !more {
+						return nil
+					}
+
+					err := cb(pc)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		})
+	}
+
+	go func() {
+		for _, pc := range records {
+			workChan <- pc
+		}
+
+		close(workChan)
+	}()
+
+	return g.Wait()
+}
@@ -0,0 +1,116 @@
+package postcodesource
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	pc := &Record{
+		Postcode:    "SW1A 1AA",
+		Inception:   "198001",
+		CountryCode: "E92000001",
+		RegionCode:  "E12000007",
+		Extra:       map[string]string{"ru11ind": "A1"},
+	}
+
+	tests := map[string]string{
+		KeyPostcode:  "SW1A 1AA",
+		KeyInception: "198001",
+		KeyCessation: "",
+		KeyCountry:   "E92000001",
+		KeyRegion:    "E12000007",
+		"ru11ind":    "A1",
+		"missing":    "",
+	}
+
+	for key, expected := range tests {
+		if got := pc.Value(key); got != expected {
+			t.Errorf("Value(%s) = %q, want %q", key, got, expected)
+		}
+	}
+}
+
+func TestPoint(t *testing.T) {
+	pc := &Record{Latitude: "51.501009", Longitude: "-0.141588"}
+
+	point, ok := pc.Point()
+	if !ok || point.Lon() != -0.141588 || point.Lat() != 51.501009 {
+		t.Errorf("Point() = %v, %t, want the coordinates", point, ok)
+	}
+
+	for _, pc := range []*Record{
+		{Latitude: NoLocationLatitude, Longitude: NoLocationLongitude},
+		{Latitude: "north", Longitude: "-0.141588"},
+	} {
+		if point, ok := pc.Point(); ok {
+			t.Errorf("Point() = %v for %+v, want no location", point, pc)
+		}
+	}
+}
+
+func TestArea(t *testing.T) {
+	tests := map[string]string{
+		"SW1A 1AA": "SW",
+		"W1M 1AA":  "W",
+		"BT1 1AA":  "BT",
+		"1AA":      "",
+	}
+
+	for postcode, expected := range tests {
+		if got := Area(postcode); got != expected {
+			t.Errorf("Area(%s) = %q, want %q", postcode, got, expected)
+		}
+	}
+}
+
+func TestMemorySource(t *testing.T) {
+	source := NewMemorySource(
+		&Record{Postcode: "SW1A 1AA"},
+		&Record{Postcode: "SW1A 1AB"},
+		&Record{Postcode: "SW1A 2AA"},
+	)
+
+	if pc, err := source.Lookup("SW1A 1AB"); err != nil || pc == nil || pc.Postcode != "SW1A 1AB" {
+		t.Errorf("Lookup(SW1A 1AB) = %+v, %v, want the record", pc, err)
+	}
+
+	if pc, err := source.Lookup("SW1A 1AD"); err != nil || pc != nil {
+		t.Errorf("Lookup(SW1A 1AD) = %+v, %v, want nil", pc, err)
+	}
+
+	if count := source.Metadata().Count; count != 3 {
+		t.Errorf("expected a count of 3, got %d", count)
+	}
+
+	var mu sync.Mutex
+	var postcodes []string
+
+	err := source.Iterate(func(pc *Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		postcodes = append(postcodes, pc.Postcode)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(postcodes)
+
+	if !slices.Equal(postcodes, []string{"SW1A 1AA", "SW1A 1AB", "SW1A 2AA"}) {
+		t.Errorf("expected every postcode iterated, got %v", postcodes)
+	}
+
+	stop := errors.New("stop")
+
+	err = source.Iterate(func(pc *Record) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("expected the callback's error from Iterate, got %v", err)
+	}
+}
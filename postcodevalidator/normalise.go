@@ -0,0 +1,100 @@
+package postcodevalidator
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Letters and digits which are easily confused when postcodes are typed in
+var digitsForLetters = strings.NewReplacer("O", "0", "I", "1")
+var lettersForDigits = strings.NewReplacer("0", "O", "1", "I")
+
+// Normalise converts a postcode to the canonical ONS pcds format, e.g.
+// SW1A 1AA, whatever its case or spacing. This covers the fixed width pcd and
+// pcd2 formats too. If the postcode still isn't valid it tries swapping O for
+// 0 and I for 1 in the positions that need a letter or a digit, as long as
+// that gives a single answer. Failures are the *ParseError from Parse.
+func Normalise(postcode string) (string, error) {
+	compact := compactUpper(postcode)
+	spaced := addSpace(compact)
+
+	pc, err := Parse(spaced)
+	if err == nil {
+		return pc.String(), nil
+	}
+
+	var candidates []string
+
+	for _, c := range confusedVariants(compact) {
+		pc, candidateErr := Parse(addSpace(c))
+		if candidateErr == nil && pc.Category == CategoryGeographic && !slices.Contains(candidates, pc.String()) {
+			candidates = append(candidates, pc.String())
+		}
+	}
+
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	return "", err
+}
+
+// Corrected returns whether Normalise only accepts the postcode after
+// swapping O for 0 or I for 1, so its canonical form is a guess at what was
+// meant rather than the same postcode written differently.
+func Corrected(postcode string) bool {
+	_, err := Parse(addSpace(compactUpper(postcode)))
+	if err == nil {
+		return false
+	}
+
+	_, err = Normalise(postcode)
+	return err == nil
+}
+
+// compactUpper upper-cases the postcode and removes any whitespace.
+func compactUpper(postcode string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return unicode.ToUpper(r)
+	}, postcode)
+}
+
+// addSpace puts a space before the inward code of a compact postcode.
+func addSpace(compact string) string {
+	if strings.HasPrefix(compact, "BFPO") {
+		return "BFPO " + compact[len("BFPO"):]
+	}
+
+	if len(compact) < 5 {
+		return compact
+	}
+
+	return compact[:len(compact)-3] + " " + compact[len(compact)-3:]
+}
+
+// confusedVariants returns the compact postcode with O and 0, and I and 1,
+// swapped to suit each possible length of area.
+func confusedVariants(compact string) []string {
+	if len(compact) < 5 || len(compact) > 7 {
+		return nil
+	}
+
+	outward := compact[:len(compact)-3]
+	inward := digitsForLetters.Replace(compact[len(compact)-3:len(compact)-2]) + compact[len(compact)-2:]
+
+	var variants []string
+
+	for areaLength := 1; areaLength <= 2 && areaLength < len(outward); areaLength++ {
+		area := lettersForDigits.Replace(outward[:areaLength])
+		district := digitsForLetters.Replace(outward[areaLength:areaLength+1]) + outward[areaLength+1:]
+
+		variants = append(variants, area+district+inward)
+	}
+
+	return variants
+}
@@ -1,12 +1,234 @@
 package postcodevalidator
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 )
 
 var postcodeRegexp = regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)
 
+// British Forces Post Office numbers, e.g. BFPO 1234
+var bfpoRegexp = regexp.MustCompile(`^BFPO \d{1,4}$`)
+
+var outwardRegexp = regexp.MustCompile(`^([A-Z]{1,2})(\d[A-Z\d]?)$`)
+var inwardRegexp = regexp.MustCompile(`^(\d)([A-Z]{2})$`)
+
+// Letters which Royal Mail doesn't use in each position of a postcode
+const (
+	illegalAreaFirst  = "QVX"
+	illegalAreaSecond = "IJZ"
+	illegalUnit       = "CIKMOV"
+)
+
+// Letters which Royal Mail does use at the end of a district, depending on
+// whether the area has one letter (e.g. W1A) or two (e.g. SW1A)
+const (
+	legalDistrictAfterOneLetter  = "ABCDEFGHJKPSTUW"
+	legalDistrictAfterTwoLetters = "ABEHMNPRVWXY"
+)
+
+// Category is the kind of place a postcode is used for.
+type Category string
+
+const (
+	// CategoryGeographic is an ordinary postcode for a place in the UK
+	CategoryGeographic Category = "geographic"
+	// CategoryNonGeographic is a postcode used only for post, such as the BX
+	// and XX areas, which isn't tied to where it's delivered
+	CategoryNonGeographic Category = "non-geographic"
+	// CategorySpecial is a postcode which doesn't follow the usual format,
+	// such as GIR 0AA or a BFPO number
+	CategorySpecial Category = "special"
+	// CategoryOverseasTerritory is a postcode for a British Overseas
+	// Territory, such as STHL 1ZZ
+	CategoryOverseasTerritory Category = "overseas territory"
+)
+
+// Areas and districts used only for post
+var nonGeographicOutwards = map[string]bool{
+	"BX":  true,
+	"XX":  true,
+	"BF1": true,
+}
+
+var specialPostcodes = map[string]bool{
+	"GIR 0AA": true,
+}
+
+var overseasTerritoryPostcodes = map[string]bool{
+	"ASCN 1ZZ": true,
+	"BBND 1ZZ": true,
+	"BIQQ 1ZZ": true,
+	"FIQQ 1ZZ": true,
+	"GX11 1AA": true,
+	"PCRN 1ZZ": true,
+	"SIQQ 1ZZ": true,
+	"STHL 1ZZ": true,
+	"TDCU 1ZZ": true,
+	"TKCA 1ZZ": true,
+}
+
+// Reason describes why a postcode failed to parse.
+type Reason string
+
+const (
+	// ReasonBadOutward is a malformed outward code, the part before the space
+	ReasonBadOutward Reason = "bad outward code"
+	// ReasonBadInward is a malformed inward code, the part after the space
+	ReasonBadInward Reason = "bad inward code"
+	// ReasonIllegalLetter is a letter Royal Mail doesn't use in its position
+	ReasonIllegalLetter Reason = "illegal letter"
+	// ReasonMissingSpace is an otherwise valid postcode without a space
+	// between the outward and inward codes
+	ReasonMissingSpace Reason = "missing space"
+)
+
+// ParseError is returned by Parse for postcodes which aren't valid.
+type ParseError struct {
+	Postcode string
+	Reason   Reason
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid postcode %s: %s", e.Postcode, e.Reason)
+}
+
+// Postcode is a UK postcode split into its parts. For SW1A 1AA the Area is
+// SW, the District 1A, the Sector 1 and the Unit AA. Special and overseas
+// territory postcodes have their whole outward code as the Area, and BFPO
+// numbers have the whole number as the Unit.
+type Postcode struct {
+	Area     string
+	District string
+	Sector   string
+	Unit     string
+	Category Category
+}
+
+// Outward returns the outward code, e.g. SW1A.
+func (p *Postcode) Outward() string {
+	return p.Area + p.District
+}
+
+// Inward returns the inward code, e.g. 1AA.
+func (p *Postcode) Inward() string {
+	return p.Sector + p.Unit
+}
+
+// SectorName returns the name of the postcode's sector, e.g. SW1A 1.
+func (p *Postcode) SectorName() string {
+	return p.Outward() + " " + p.Sector
+}
+
+func (p *Postcode) String() string {
+	return p.Outward() + " " + p.Inward()
+}
+
 // Validate returns a boolean depending on whether the postcode is a full valid UK postcode
 func Validate(postcode string) bool {
-	return postcodeRegexp.MatchString(postcode)
+	return postcodeRegexp.MatchString(postcode) || parseUnusual(postcode) != nil
+}
+
+// Parse splits a postcode in the ONS format, with a single space between the
+// outward and inward codes, into its parts. It's stricter than Validate, as it
+// also checks the letters used in each position. Failures are a *ParseError.
+func Parse(postcode string) (*Postcode, error) {
+	if p := parseUnusual(postcode); p != nil {
+		return p, nil
+	}
+
+	outward, inward, found := strings.Cut(postcode, " ")
+
+	if !found {
+		if len(postcode) > 3 {
+			if _, err := parseParts(postcode, postcode[:len(postcode)-3], postcode[len(postcode)-3:]); err == nil {
+				return nil, &ParseError{Postcode: postcode, Reason: ReasonMissingSpace}
+			}
+		}
+
+		return nil, &ParseError{Postcode: postcode, Reason: ReasonBadOutward}
+	}
+
+	return parseParts(postcode, outward, inward)
+}
+
+func parseParts(postcode string, outward string, inward string) (*Postcode, error) {
+	outwardMatch := outwardRegexp.FindStringSubmatch(outward)
+	if outwardMatch == nil {
+		return nil, &ParseError{Postcode: postcode, Reason: ReasonBadOutward}
+	}
+
+	inwardMatch := inwardRegexp.FindStringSubmatch(inward)
+	if inwardMatch == nil {
+		return nil, &ParseError{Postcode: postcode, Reason: ReasonBadInward}
+	}
+
+	p := &Postcode{
+		Area:     outwardMatch[1],
+		District: outwardMatch[2],
+		Sector:   inwardMatch[1],
+		Unit:     inwardMatch[2],
+		Category: CategoryGeographic,
+	}
+
+	if nonGeographicOutwards[p.Area] || nonGeographicOutwards[p.Outward()] {
+		p.Category = CategoryNonGeographic
+	}
+
+	if !legalLetters(p) {
+		return nil, &ParseError{Postcode: postcode, Reason: ReasonIllegalLetter}
+	}
+
+	return p, nil
+}
+
+// parseUnusual parses the special and overseas territory postcodes which
+// don't follow the usual format, returning nil for any other postcode.
+func parseUnusual(postcode string) *Postcode {
+	if bfpoRegexp.MatchString(postcode) {
+		return &Postcode{Area: "BFPO", Unit: postcode[len("BFPO "):], Category: CategorySpecial}
+	}
+
+	category := CategorySpecial
+	if overseasTerritoryPostcodes[postcode] {
+		category = CategoryOverseasTerritory
+	} else if !specialPostcodes[postcode] {
+		return nil
+	}
+
+	outward, inward, _ := strings.Cut(postcode, " ")
+	return &Postcode{Area: outward, Sector: inward[:1], Unit: inward[1:], Category: category}
+}
+
+func legalLetters(p *Postcode) bool {
+	// XX isn't otherwise a legal area
+	if p.Category == CategoryNonGeographic && p.Area == "XX" {
+		return !strings.ContainsAny(p.Unit, illegalUnit)
+	}
+
+	if strings.ContainsAny(p.Area[:1], illegalAreaFirst) {
+		return false
+	}
+
+	if len(p.Area) == 2 && strings.ContainsAny(p.Area[1:], illegalAreaSecond) {
+		return false
+	}
+
+	if len(p.District) == 2 && !isDigit(p.District[1]) {
+		legal := legalDistrictAfterOneLetter
+		if len(p.Area) == 2 {
+			legal = legalDistrictAfterTwoLetters
+		}
+
+		if !strings.ContainsRune(legal, rune(p.District[1])) {
+			return false
+		}
+	}
+
+	return !strings.ContainsAny(p.Unit, illegalUnit)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
 }
@@ -0,0 +1,123 @@
+package postcodevalidator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	pc, err := Parse("SW1A 1AA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pc.Area != "SW" || pc.District != "1A" || pc.Sector != "1" || pc.Unit != "AA" {
+		t.Errorf("unexpected parts %+v", pc)
+	}
+
+	if pc.Outward() != "SW1A" || pc.Inward() != "1AA" {
+		t.Errorf("unexpected outward %s and inward %s", pc.Outward(), pc.Inward())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]Reason{
+		"SW1A1AA":  ReasonMissingSpace,
+		"1W1A 1AA": ReasonBadOutward,
+		"SW1A 1A":  ReasonBadInward,
+		"QW1A 1AA": ReasonIllegalLetter,
+		"SW1A 1CA": ReasonIllegalLetter,
+		"W1M 1AA":  ReasonIllegalLetter,
+		"sw1a 1aa": ReasonBadOutward,
+	}
+
+	for postcode, reason := range tests {
+		_, err := Parse(postcode)
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("expected a ParseError for %s, got %v", postcode, err)
+			continue
+		}
+
+		if parseErr.Reason != reason {
+			t.Errorf("expected %s for %s, got %s", reason, postcode, parseErr.Reason)
+		}
+	}
+}
+
+func TestParseCategory(t *testing.T) {
+	tests := map[string]Category{
+		"SW1A 1AA":  CategoryGeographic,
+		"BX1 1LT":   CategoryNonGeographic,
+		"XX1 1AA":   CategoryNonGeographic,
+		"GIR 0AA":   CategorySpecial,
+		"BFPO 1234": CategorySpecial,
+		"STHL 1ZZ":  CategoryOverseasTerritory,
+		"GX11 1AA":  CategoryOverseasTerritory,
+	}
+
+	for postcode, category := range tests {
+		pc, err := Parse(postcode)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", postcode, err)
+			continue
+		}
+
+		if pc.Category != category {
+			t.Errorf("expected %s to be %s, got %s", postcode, category, pc.Category)
+		}
+
+		if !Validate(postcode) {
+			t.Errorf("expected %s to validate", postcode)
+		}
+	}
+}
+
+func TestNormalise(t *testing.T) {
+	tests := map[string]string{
+		"SW1A 1AA":  "SW1A 1AA",
+		"sw1a1aa":   "SW1A 1AA",
+		"SW1A  1AA": "SW1A 1AA",
+		"AB1  0AA":  "AB1 0AA",
+		"AB101AA":   "AB10 1AA",
+		"SW1A IAA":  "SW1A 1AA",
+		"0X1 1AA":   "OX1 1AA",
+		"OXI 1AA":   "OX1 1AA",
+		"gir0aa":    "GIR 0AA",
+		"BFPO1234":  "BFPO 1234",
+	}
+
+	for postcode, expected := range tests {
+		normalised, err := Normalise(postcode)
+		if err != nil {
+			t.Errorf("failed to normalise %s: %s", postcode, err)
+			continue
+		}
+
+		if normalised != expected {
+			t.Errorf("expected %s to normalise to %s, got %s", postcode, expected, normalised)
+		}
+	}
+
+	_, err := Normalise("NOT A POSTCODE")
+	if err == nil {
+		t.Error("expected an error normalising NOT A POSTCODE")
+	}
+}
+
+func TestCorrected(t *testing.T) {
+	tests := map[string]bool{
+		"SW1A 1AA":       false,
+		"sw1a1aa":        false,
+		"SW1A IAA":       true,
+		"0X1 1AA":        true,
+		"NOT A POSTCODE": false,
+	}
+
+	for postcode, expected := range tests {
+		if Corrected(postcode) != expected {
+			t.Errorf("expected Corrected(%s) to be %t", postcode, expected)
+		}
+	}
+}
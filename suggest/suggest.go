@@ -0,0 +1,209 @@
+package suggest
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+// Suggestion is a live postcode which a WOF record may have been meant to be.
+// Distance is in metres, and is negative if either has no location.
+type Suggestion struct {
+	Candidate    *postcodesource.Record
+	EditDistance int
+	Distance     float64
+}
+
+// Suggester finds live postcodes close to ones which are about to be
+// deprecated or ceased.
+type Suggester struct {
+	byOutward   map[string][]*postcodesource.Record
+	maxEdits    int
+	maxDistance float64
+}
+
+// NewSuggester indexes the live postcodes in the source. Suggestions are at
+// most maxEdits edits away from the postcode, in the same outward code, and
+// within maxDistance metres of it if both have a location.
+func NewSuggester(source postcodesource.PostcodeSource, maxEdits int, maxDistance float64) (*Suggester, error) {
+	s := &Suggester{
+		byOutward:   make(map[string][]*postcodesource.Record),
+		maxEdits:    maxEdits,
+		maxDistance: maxDistance,
+	}
+
+	mu := sync.Mutex{}
+
+	err := source.Iterate(func(pc *postcodesource.Record) error {
+		if pc.Cessation != "" {
+			return nil
+		}
+
+		outward := outwardCode(pc.Postcode)
+
+		mu.Lock()
+		s.byOutward[outward] = append(s.byOutward[outward], pc)
+		mu.Unlock()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Suggest returns the candidates for the postcode, nearest first. point is
+// the WOF record's current location, if it has one.
+func (s *Suggester) Suggest(postcode string, point orb.Point, hasPoint bool) []*Suggestion {
+	compact := compactPostcode(postcode)
+
+	var suggestions []*Suggestion
+
+	for _, pc := range s.byOutward[outwardCode(postcode)] {
+		candidate := compactPostcode(pc.Postcode)
+		if candidate == compact {
+			continue
+		}
+
+		edits := levenshtein(compact, candidate)
+		if edits > s.maxEdits {
+			continue
+		}
+
+		distance := -1.0
+
+		if candidatePoint, ok := pc.Point(); ok && hasPoint {
+			distance = geo.DistanceHaversine(point, candidatePoint)
+			if distance > s.maxDistance {
+				continue
+			}
+		}
+
+		suggestions = append(suggestions, &Suggestion{Candidate: pc, EditDistance: edits, Distance: distance})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+
+		if a.EditDistance != b.EditDistance {
+			return a.EditDistance < b.EditDistance
+		}
+
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+
+		return a.Candidate.Postcode < b.Candidate.Postcode
+	})
+
+	return suggestions
+}
+
+// ReviewWriter writes suggestions to a CSV for an editor to review. It's safe
+// to use from several goroutines at once.
+type ReviewWriter struct {
+	mu sync.Mutex
+	w  *csv.Writer
+}
+
+// NewReviewWriter writes the header row of the review CSV to out.
+func NewReviewWriter(out io.Writer) (*ReviewWriter, error) {
+	w := csv.NewWriter(out)
+
+	err := w.Write([]string{"wof_id", "wof_name", "action", "candidate", "edit_distance", "distance_m"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReviewWriter{w: w}, nil
+}
+
+// Write adds a row for each suggestion for the WOF record. action is what the
+// sync is doing to the record, e.g. deprecated.
+func (r *ReviewWriter) Write(id string, name string, action string, suggestions []*Suggestion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range suggestions {
+		distance := ""
+		if s.Distance >= 0 {
+			distance = strconv.FormatFloat(s.Distance, 'f', 0, 64)
+		}
+
+		err := r.w.Write([]string{id, name, action, s.Candidate.Postcode, strconv.Itoa(s.EditDistance), distance})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush writes any buffered rows.
+func (r *ReviewWriter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// compactPostcode uppercases the postcode and strips its whitespace, so that
+// spacing mistakes don't count as edits.
+func compactPostcode(postcode string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return unicode.ToUpper(r)
+	}, postcode)
+}
+
+// outwardCode returns everything but the three character inward code, which
+// works for postcodes too malformed to parse.
+func outwardCode(postcode string) string {
+	compact := compactPostcode(postcode)
+	if len(compact) <= 3 {
+		return compact
+	}
+
+	return compact[:len(compact)-3]
+}
+
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
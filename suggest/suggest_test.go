@@ -0,0 +1,49 @@
+package suggest
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+
+	"github.com/whosonfirst/wof-sync-os-postcodes/postcodesource"
+)
+
+func TestSuggest(t *testing.T) {
+	s := &Suggester{
+		byOutward: map[string][]*postcodesource.Record{
+			"SW1A": {
+				{Postcode: "SW1A 1AA", Latitude: "51.501009", Longitude: "-0.141588"},
+				{Postcode: "SW1A 2AA", Latitude: "51.503541", Longitude: "-0.127670"},
+				{Postcode: "SW1A 0AA", Latitude: "51.499840", Longitude: "-0.124663"},
+			},
+		},
+		maxEdits:    1,
+		maxDistance: 500,
+	}
+
+	suggestions := s.Suggest("SW1A 1AB", orb.Point{-0.1416, 51.5010}, true)
+
+	if len(suggestions) != 1 {
+		t.Fatalf("expected 1 suggestion, got %d", len(suggestions))
+	}
+
+	if suggestions[0].Candidate.Postcode != "SW1A 1AA" {
+		t.Errorf("expected SW1A 1AA, got %s", suggestions[0].Candidate.Postcode)
+	}
+
+	suggestions = s.Suggest("SW1A 1AB", orb.Point{}, false)
+
+	if len(suggestions) != 1 {
+		t.Fatalf("expected 1 suggestion without a location, got %d", len(suggestions))
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	if d := levenshtein("SW1A1AA", "SW1A1AB"); d != 1 {
+		t.Errorf("expected 1, got %d", d)
+	}
+
+	if d := levenshtein("SW1A1AA", "SW11AA"); d != 1 {
+		t.Errorf("expected 1, got %d", d)
+	}
+}